@@ -4,10 +4,14 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"github.com/xanzy/go-gitlab"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/protocol/grpc"
 	"bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/service/v1"
@@ -18,6 +22,28 @@ type Config struct {
 	GRPCPort string
 	GitlabToken string
 	GitlabURL string
+	CANamespace string
+	ExecAllowedCommands string
+}
+
+//Namespace janitor is running in, used when no CA namespace was given explicitly
+func currentNamespace() string {
+	ns, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return "default"
+	}
+	return strings.TrimSpace(string(ns))
+}
+
+//Split comma separated list of commands allowed in exec sessions, empty list disables exec
+func allowedCommands(list string) []string {
+	commands := make([]string, 0)
+	for _, command := range strings.Split(list, ",") {
+		if command = strings.TrimSpace(command); len(command) > 0 {
+			commands = append(commands, command)
+		}
+	}
+	return commands
 }
 
 // RunServer runs gRPC server and HTTP gateway
@@ -29,12 +55,18 @@ func RunServer() error {
 	flag.StringVar(&cfg.GRPCPort, "port", "", "gRPC port to bind")
 	flag.StringVar(&cfg.GitlabToken, "token", "", "Gitlab token")
 	flag.StringVar(&cfg.GitlabURL, "url", "", "Gitlab API URL")
+	flag.StringVar(&cfg.CANamespace, "ca-namespace", "", "Namespace of the internal CA secret")
+	flag.StringVar(&cfg.ExecAllowedCommands, "exec-allow", "", "Comma separated list of commands allowed to be executed in instance containers, commands given by path only match entries with the same path, * allows any command; shells only run commands given with -c and without terminal")
 	flag.Parse()
 
 	if len(cfg.GRPCPort) == 0 {
 		return fmt.Errorf("invalid TCP port for gRPC server: '%s'", cfg.GRPCPort)
 	}
 
+	if len(cfg.CANamespace) == 0 {
+		cfg.CANamespace = currentNamespace()
+	}
+
 	//Initialize kubernetes API
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -48,20 +80,36 @@ func RunServer() error {
 
 	kubeAPI := clientset
 
+	dynAPI, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	//Initialize Gitlab API
 	gitAPI, err := gitlab.NewClient(cfg.GitlabToken, gitlab.WithBaseURL(cfg.GitlabURL))
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	//Remove expired credentials left behind by basic auth rotations
+	go v1.RunCredentialRotationSweeper(ctx, kubeAPI, time.Minute)
+
+	//Renew certificates issued by the internal CA before they expire
+	go v1.RunInternalCaRenewer(ctx, kubeAPI, cfg.CANamespace, time.Hour)
+
 	confAPI := v1.NewConfigServiceServer(kubeAPI, gitAPI)
 	authAPI := v1.NewBasicAuthServiceServer(kubeAPI)
-	certAPI := v1.NewCertManagerServiceServer(kubeAPI)
+	accessAPI := v1.NewAccessControlServiceServer(kubeAPI)
+	certAPI := v1.NewCertManagerServiceServer(kubeAPI, dynAPI, cfg.CANamespace)
 	readyAPI := v1.NewReadinessServiceServer(kubeAPI)
-	infoAPI := v1.NewInformationServiceServer(kubeAPI)
-	podAPI := v1.NewPodServiceServer(kubeAPI)
+	lifecycleAPI := v1.NewInstanceLifecycleServiceServer(kubeAPI)
+	helmAPI := v1.NewHelmServiceServer(kubeAPI)
+	infoAPI := v1.NewInformationServiceServer(kubeAPI, dynAPI)
+	podAPI := v1.NewPodServiceServer(kubeAPI, config)
 	namespaceAPI := v1.NewNamespaceServiceServer(kubeAPI)
+	execAPI := v1.NewExecServiceServer(kubeAPI, config, allowedCommands(cfg.ExecAllowedCommands))
+	eventAPI := v1.NewEventServiceServer(kubeAPI)
 
-	return grpc.RunServer(ctx, confAPI, authAPI, certAPI, readyAPI, infoAPI, podAPI, namespaceAPI, cfg.GRPCPort)
+	return grpc.RunServer(ctx, confAPI, authAPI, accessAPI, certAPI, readyAPI, lifecycleAPI, helmAPI, infoAPI, podAPI, namespaceAPI, execAPI, eventAPI, cfg.GRPCPort)
 }
 
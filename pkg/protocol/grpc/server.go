@@ -14,11 +14,16 @@ import (
 func RunServer(ctx context.Context,
                confAPI v1.ConfigServiceServer,
                authAPI v1.BasicAuthServiceServer,
+               accessAPI v1.AccessControlServiceServer,
                certAPI v1.CertManagerServiceServer,
                readyAPI v1.ReadinessServiceServer,
+               lifecycleAPI v1.InstanceLifecycleServiceServer,
+               helmAPI v1.HelmServiceServer,
                infoAPI v1.InformationServiceServer,
                podAPI v1.PodServiceServer,
                namespaceAPI v1.NamespaceServiceServer,
+               execAPI v1.ExecServiceServer,
+               eventAPI v1.EventServiceServer,
                port string) error {
 	listen, err := net.Listen("tcp", ":"+port)
 	if err != nil {
@@ -29,11 +34,16 @@ func RunServer(ctx context.Context,
 	server := grpc.NewServer()
 	v1.RegisterConfigServiceServer(server, confAPI)
 	v1.RegisterBasicAuthServiceServer(server, authAPI)
+	v1.RegisterAccessControlServiceServer(server, accessAPI)
 	v1.RegisterCertManagerServiceServer(server, certAPI)
 	v1.RegisterReadinessServiceServer(server, readyAPI)
+	v1.RegisterInstanceLifecycleServiceServer(server, lifecycleAPI)
+	v1.RegisterHelmServiceServer(server, helmAPI)
 	v1.RegisterInformationServiceServer(server, infoAPI)
 	v1.RegisterPodServiceServer(server, podAPI)
 	v1.RegisterNamespaceServiceServer(server, namespaceAPI)
+	v1.RegisterExecServiceServer(server, execAPI)
+	v1.RegisterEventServiceServer(server, eventAPI)
 
 	// graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -0,0 +1,291 @@
+package v1
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	instanceLabel = "app.kubernetes.io/instance"
+	sourceRangeAnnotation = "nginx.ingress.kubernetes.io/whitelist-source-range"
+	authTlsSecretAnnotation = "nginx.ingress.kubernetes.io/auth-tls-secret"
+	authTlsVerifyClientAnnotation = "nginx.ingress.kubernetes.io/auth-tls-verify-client"
+	authTlsVerifyDepthAnnotation = "nginx.ingress.kubernetes.io/auth-tls-verify-depth"
+	clientCaSecretKey = "ca.crt"
+	defaultVerifyDepth = 1
+)
+
+type accessControlServiceServer struct {
+	kubeAPI kubernetes.Interface
+}
+
+func NewAccessControlServiceServer(kubeAPI kubernetes.Interface) v1.AccessControlServiceServer {
+	return &accessControlServiceServer{kubeAPI: kubeAPI}
+}
+
+func getClientCaSecretName(uid string) string {
+	return uid + "-client-ca"
+}
+
+//Check if resource is labelled with the instance, named exactly after it or owned by an object named after it.
+//Name prefixes are not enough, as uid of one instance may be a prefix of another one in the same namespace.
+func belongsToInstance(object metav1.Object, uid string) bool {
+	if object.GetName() == uid || object.GetLabels()[instanceLabel] == uid {
+		return true
+	}
+	for _, owner := range object.GetOwnerReferences() {
+		if owner.Name == uid {
+			return true
+		}
+	}
+	return false
+}
+
+//Check if name was generated by controller for its child, e.g. ReplicaSet <deployment>-<pod template hash> or Job <cronjob>-<schedule>
+func isGeneratedChildName(name string, controller string) bool {
+	suffix, found := strings.CutPrefix(name, controller + "-")
+	return found && len(suffix) > 0 && !strings.Contains(suffix, "-")
+}
+
+//Check if ingress belongs to instance, either by name or by the Helm instance label
+func isInstanceIngress(ingress *networkingv1.Ingress, uid string) bool {
+	return belongsToInstance(ingress, uid)
+}
+
+//Retrieve all ingresses belonging to instance
+func findInstanceIngresses(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, uid string) ([]networkingv1.Ingress, error) {
+	ingresses, err := kubeAPI.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]networkingv1.Ingress, 0)
+	for _, ingress := range ingresses.Items {
+		if isInstanceIngress(&ingress, uid) {
+			matching = append(matching, ingress)
+		}
+	}
+	return matching, nil
+}
+
+//Normalize source ranges to CIDR notation, single addresses are converted to host ranges
+func normalizeSourceRanges(ranges []string) ([]string, error) {
+	result := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		r = strings.TrimSpace(r)
+		if _, network, err := net.ParseCIDR(r); err == nil {
+			result = append(result, network.String())
+			continue
+		}
+		ip := net.ParseIP(r)
+		if ip == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid source range '%s'", r)
+		}
+		if ip.To4() != nil {
+			result = append(result, ip.String() + "/32")
+		} else {
+			result = append(result, ip.String() + "/128")
+		}
+	}
+	return result, nil
+}
+
+//Verify that given PEM bundle contains at least one CA certificate
+func validateClientCa(bundle string) error {
+	rest := []byte(bundle)
+	found := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid client CA certificate: %v", err)
+		}
+		found++
+	}
+	if found == 0 {
+		return status.Errorf(codes.InvalidArgument, "no certificate found in client CA bundle")
+	}
+	return nil
+}
+
+//Prepare merge patch setting (or removing when value is nil) ingress annotations
+func prepareAnnotationsPatch(annotations map[string]interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+}
+
+func (s *accessControlServiceServer) patchInstanceIngresses(ctx context.Context, depl *v1.Instance, annotations map[string]interface{}) (int, error) {
+	ingresses, err := findInstanceIngresses(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return 0, err
+	}
+
+	patch, err := prepareAnnotationsPatch(annotations)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ingress := range ingresses {
+		logLine(fmt.Sprintf("Patching access control annotations of ingress %s", ingress.Name))
+		_, err = s.kubeAPI.NetworkingV1().Ingresses(depl.Namespace).Patch(ctx, ingress.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(ingresses), nil
+}
+
+func (s *accessControlServiceServer) createOrReplaceClientCaSecret(ctx context.Context, depl *v1.Instance, bundle string) error {
+	secretName := getClientCaSecretName(depl.Uid)
+
+	secret, err := s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, secretName, metav1.GetOptions{})
+	//Secret does not exist, we have to create it
+	if err != nil {
+		secret = &apiv1.Secret{}
+		secret.SetNamespace(depl.Namespace)
+		secret.SetName(secretName)
+		secret.Data = map[string][]byte{clientCaSecretKey: []byte(bundle)}
+		_, err = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+
+	secret.Data = map[string][]byte{clientCaSecretKey: []byte(bundle)}
+	_, err = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+func (s *accessControlServiceServer) CreateOrReplace(ctx context.Context, req *v1.InstanceAccessPolicyRequest) (*v1.ServiceResponse, error) {
+	logLine("> Entered AccessControl CreateOrReplace method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Instance
+	policy := req.Policy
+	if policy == nil {
+		policy = &v1.AccessPolicy{}
+	}
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, namespaceNotFound), err
+	}
+
+	ranges, err := normalizeSourceRanges(policy.SourceRanges)
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Invalid source range"), err
+	}
+
+	annotations := make(map[string]interface{})
+	if len(ranges) > 0 {
+		annotations[sourceRangeAnnotation] = strings.Join(ranges, ",")
+	} else {
+		annotations[sourceRangeAnnotation] = nil
+	}
+
+	if len(policy.ClientCaCertificate) > 0 {
+		if err = validateClientCa(policy.ClientCaCertificate); err != nil {
+			return prepareResponse(v1.Status_FAILED, "Invalid client CA certificate"), err
+		}
+		if err = s.createOrReplaceClientCaSecret(ctx, depl, policy.ClientCaCertificate); err != nil {
+			return prepareResponse(v1.Status_FAILED, "Error while creating client CA secret!"), err
+		}
+
+		depth := int(policy.VerifyDepth)
+		if depth <= 0 {
+			depth = defaultVerifyDepth
+		}
+		annotations[authTlsSecretAnnotation] = depl.Namespace + "/" + getClientCaSecretName(depl.Uid)
+		annotations[authTlsVerifyClientAnnotation] = "on"
+		annotations[authTlsVerifyDepthAnnotation] = strconv.Itoa(depth)
+	} else {
+		annotations[authTlsSecretAnnotation] = nil
+		annotations[authTlsVerifyClientAnnotation] = nil
+		annotations[authTlsVerifyDepthAnnotation] = nil
+	}
+
+	count, err := s.patchInstanceIngresses(ctx, depl, annotations)
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Error while patching ingress!"), err
+	}
+	if count == 0 {
+		return prepareResponse(v1.Status_FAILED, "Ingress not found!"), status.Errorf(codes.NotFound, "no ingress found for instance %s", depl.Uid)
+	}
+
+	//client CA secret is no longer referenced
+	if len(policy.ClientCaCertificate) == 0 {
+		_ = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Delete(ctx, getClientCaSecretName(depl.Uid), metav1.DeleteOptions{})
+	}
+
+	logLine(fmt.Sprintf("< Access policy applied to %d ingress(es)", count))
+	return prepareResponse(v1.Status_OK, fmt.Sprintf("Access policy applied to %d ingress(es)", count)), nil
+}
+
+func (s *accessControlServiceServer) DeleteIfExists(ctx context.Context, req *v1.InstanceRequest) (*v1.ServiceResponse, error) {
+	logLine("> Entered AccessControl DeleteIfExists method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, namespaceNotFound), err
+	}
+
+	annotations := map[string]interface{}{
+		sourceRangeAnnotation: nil,
+		authTlsSecretAnnotation: nil,
+		authTlsVerifyClientAnnotation: nil,
+		authTlsVerifyDepthAnnotation: nil,
+	}
+	_, err = s.patchInstanceIngresses(ctx, depl, annotations)
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Error while patching ingress!"), err
+	}
+
+	secretName := getClientCaSecretName(depl.Uid)
+
+	//check if secret exist
+	_, err = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_OK, "Access policy removed successfully"), nil
+	}
+
+	//delete secret
+	err = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Error while removing secret!"), err
+	}
+	return prepareResponse(v1.Status_OK, "Access policy removed successfully"), nil
+}
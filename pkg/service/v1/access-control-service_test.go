@@ -0,0 +1,227 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"math/big"
+	"testing"
+	"time"
+)
+
+//Generate self-signed CA certificate and return it PEM encoded
+func generateTestCaPem(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{CommonName: "test-ca"},
+		NotBefore: time.Now(),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestNormalizeSourceRanges(t *testing.T) {
+	ranges, err := normalizeSourceRanges([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::1", " 172.16.5.4/16 "})
+	if err != nil || len(ranges) != 4 {
+		t.Fatal(err)
+	}
+	if ranges[0] != "10.0.0.0/8" || ranges[1] != "192.168.1.1/32" || ranges[2] != "2001:db8::1/128" || ranges[3] != "172.16.0.0/16" {
+		t.Fail()
+	}
+
+	_, err = normalizeSourceRanges([]string{"not-an-ip"})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestBelongsToInstance(t *testing.T) {
+	ingress := networkingv1.Ingress{}
+	ingress.Name = "test-uid-def"
+	//Fail on name prefix only
+	if belongsToInstance(&ingress, "test-uid") {
+		t.Fail()
+	}
+	//Pass on exact name, instance label and owner
+	if !belongsToInstance(&ingress, "test-uid-def") {
+		t.Fail()
+	}
+	ingress.Name = "web"
+	ingress.Labels = map[string]string{instanceLabel: "test-uid"}
+	if !belongsToInstance(&ingress, "test-uid") {
+		t.Fail()
+	}
+	ingress.Labels = nil
+	ingress.OwnerReferences = []metav1.OwnerReference{{Kind: "Deployment", Name: "test-uid"}}
+	if !belongsToInstance(&ingress, "test-uid") {
+		t.Fail()
+	}
+
+	if !isGeneratedChildName("test-uid-5d9c", "test-uid") || isGeneratedChildName("test-uid-def-5d9c", "test-uid") || isGeneratedChildName("test-uid-", "test-uid") {
+		t.Fail()
+	}
+}
+
+func TestAccessControlServiceServer_CreateOrReplace(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewAccessControlServiceServer(client)
+
+	policy := v1.AccessPolicy{SourceRanges: []string{"10.0.0.0/8"}, ClientCaCertificate: generateTestCaPem(t)}
+
+	//Fail on API version check
+	illreq := v1.InstanceAccessPolicyRequest{Api: "dummy", Instance: &inst, Policy: &policy}
+	res, err := server.CreateOrReplace(context.Background(), &illreq)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	acReq := v1.InstanceAccessPolicyRequest{Api: apiVersion, Instance: &inst, Policy: &policy}
+	res, err = server.CreateOrReplace(context.Background(), &acReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing ingress
+	res, err = server.CreateOrReplace(context.Background(), &acReq)
+	if err == nil || res.Status != v1.Status_FAILED || res.Message != "Ingress not found!" {
+		t.Fail()
+	}
+
+	//create mock ingresses, only the first one belongs to instance
+	i1 := networkingv1.Ingress{}
+	i1.Name = "test-uid"
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").Create(context.Background(), &i1, metav1.CreateOptions{})
+	i2 := networkingv1.Ingress{}
+	i2.Name = "other-uid"
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").Create(context.Background(), &i2, metav1.CreateOptions{})
+
+	//Fail on invalid source range
+	invalid := v1.AccessPolicy{SourceRanges: []string{"10.0.0.300"}}
+	invReq := v1.InstanceAccessPolicyRequest{Api: apiVersion, Instance: &inst, Policy: &invalid}
+	res, err = server.CreateOrReplace(context.Background(), &invReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Pass
+	res, err = server.CreateOrReplace(context.Background(), &acReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	ing, _ := client.NetworkingV1().Ingresses("test-namespace").Get(context.Background(), "test-uid", metav1.GetOptions{})
+	if ing.Annotations[sourceRangeAnnotation] != "10.0.0.0/8" || ing.Annotations[authTlsSecretAnnotation] != "test-namespace/test-uid-client-ca" {
+		t.Fail()
+	}
+	other, _ := client.NetworkingV1().Ingresses("test-namespace").Get(context.Background(), "other-uid", metav1.GetOptions{})
+	if len(other.Annotations) != 0 {
+		t.Fail()
+	}
+	sec, err := client.CoreV1().Secrets("test-namespace").Get(context.Background(), getClientCaSecretName("test-uid"), metav1.GetOptions{})
+	if err != nil || len(sec.Data[clientCaSecretKey]) == 0 {
+		t.Fail()
+	}
+
+	//Pass on removing client certificate requirement
+	rangesOnly := v1.AccessPolicy{SourceRanges: []string{"192.168.0.0/16"}}
+	rangesReq := v1.InstanceAccessPolicyRequest{Api: apiVersion, Instance: &inst, Policy: &rangesOnly}
+	res, err = server.CreateOrReplace(context.Background(), &rangesReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	ing, _ = client.NetworkingV1().Ingresses("test-namespace").Get(context.Background(), "test-uid", metav1.GetOptions{})
+	if ing.Annotations[sourceRangeAnnotation] != "192.168.0.0/16" {
+		t.Fail()
+	}
+	if _, found := ing.Annotations[authTlsSecretAnnotation]; found {
+		t.Fail()
+	}
+	_, err = client.CoreV1().Secrets("test-namespace").Get(context.Background(), getClientCaSecretName("test-uid"), metav1.GetOptions{})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestAccessControlServiceServer_DeleteIfExists(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewAccessControlServiceServer(client)
+
+	//Fail on API version check
+	res, err := server.DeleteIfExists(context.Background(), &illegal_req)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	freq := v1.InstanceRequest{Api: apiVersion, Deployment: &fake_ns_inst}
+	res, err = server.DeleteIfExists(context.Background(), &freq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Pass if nothing configured
+	res, err = server.DeleteIfExists(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	//create mock ingress with access control annotations
+	i1 := networkingv1.Ingress{}
+	i1.Name = "test-uid-web"
+	i1.Labels = map[string]string{instanceLabel: "test-uid"}
+	i1.Annotations = map[string]string{sourceRangeAnnotation: "10.0.0.0/8", "other": "value"}
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").Create(context.Background(), &i1, metav1.CreateOptions{})
+	//create mock ingress of another instance whose uid starts with the same prefix
+	i2 := networkingv1.Ingress{}
+	i2.Name = "test-uid-def"
+	i2.Labels = map[string]string{instanceLabel: "test-uid-def"}
+	i2.Annotations = map[string]string{sourceRangeAnnotation: "10.0.0.0/8"}
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").Create(context.Background(), &i2, metav1.CreateOptions{})
+
+	//Pass
+	res, err = server.DeleteIfExists(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	ing, _ := client.NetworkingV1().Ingresses("test-namespace").Get(context.Background(), "test-uid-web", metav1.GetOptions{})
+	if _, found := ing.Annotations[sourceRangeAnnotation]; found || ing.Annotations["other"] != "value" {
+		t.Fail()
+	}
+	other, _ := client.NetworkingV1().Ingresses("test-namespace").Get(context.Background(), "test-uid-def", metav1.GetOptions{})
+	if other.Annotations[sourceRangeAnnotation] != "10.0.0.0/8" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,288 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	gatewayApiGroup = "gateway.networking.k8s.io"
+)
+
+var httpRouteResource = schema.GroupVersionResource{Group: gatewayApiGroup, Version: "v1", Resource: "httproutes"}
+var gatewayResource = schema.GroupVersionResource{Group: gatewayApiGroup, Version: "v1", Resource: "gateways"}
+
+//Prepare access urls response
+func prepareAccessUrlsResponse(status v1.Status, message string, urls []*v1.AccessUrl) *v1.AccessUrlsResponse {
+	return &v1.AccessUrlsResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Urls: urls,
+	}
+}
+
+//Check if hostname matches given pattern, which can be a wildcard covering a single label
+func hostnameMatches(pattern string, host string) bool {
+	if pattern == host {
+		return true
+	}
+	if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+		return !strings.Contains(strings.TrimSuffix(host, pattern[1:]), ".")
+	}
+	return false
+}
+
+func buildAccessUrl(tls bool, host string, path string) string {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	if len(path) == 0 {
+		path = "/"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, host, path)
+}
+
+//Build urls for every host and path served by ingress
+func describeIngressUrls(ingress *networkingv1.Ingress) []*v1.AccessUrl {
+	addresses := make([]string, 0)
+	for _, lb := range ingress.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			addresses = append(addresses, lb.IP)
+		}
+		if lb.Hostname != "" {
+			addresses = append(addresses, lb.Hostname)
+		}
+	}
+
+	urls := make([]*v1.AccessUrl, 0)
+	for _, rule := range ingress.Spec.Rules {
+		host := rule.Host
+		if len(host) == 0 {
+			//rule without host is served under the address of ingress controller
+			if len(addresses) == 0 {
+				continue
+			}
+			host = addresses[0]
+		}
+
+		tls, secretName := false, ""
+		for _, entry := range ingress.Spec.TLS {
+			for _, pattern := range entry.Hosts {
+				if hostnameMatches(pattern, host) {
+					tls, secretName = true, entry.SecretName
+				}
+			}
+		}
+
+		paths := []string{"/"}
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+			paths = make([]string, 0, len(rule.HTTP.Paths))
+			for _, path := range rule.HTTP.Paths {
+				paths = append(paths, path.Path)
+			}
+		}
+
+		for _, path := range paths {
+			urls = append(urls, &v1.AccessUrl{
+				Url: buildAccessUrl(tls, host, path),
+				Source: "Ingress/" + ingress.Name,
+				Tls: tls,
+				TlsSecretName: secretName,
+				AddressAssigned: len(addresses) > 0,
+				Addresses: addresses,
+			})
+		}
+	}
+	return urls
+}
+
+//Listener of a gateway which route is attached to
+type gatewayListener struct {
+	hostname string
+	tls bool
+	secretName string
+	addresses []string
+}
+
+//Retrieve listeners of gateways referenced by given route
+func (s *informationServiceServer) findRouteListeners(ctx context.Context, route *unstructured.Unstructured) []gatewayListener {
+	listeners := make([]gatewayListener, 0)
+	parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	for _, p := range parentRefs {
+		ref, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := ref["name"].(string)
+		namespace, _ := ref["namespace"].(string)
+		if len(namespace) == 0 {
+			namespace = route.GetNamespace()
+		}
+		sectionName, _ := ref["sectionName"].(string)
+
+		gateway, err := s.dynAPI.Resource(gatewayResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			logLine(fmt.Sprintf("Could not retrieve gateway %s/%s: %v", namespace, name, err))
+			continue
+		}
+
+		addresses := make([]string, 0)
+		gatewayAddresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+		for _, a := range gatewayAddresses {
+			if address, ok := a.(map[string]interface{}); ok {
+				if value, _ := address["value"].(string); len(value) > 0 {
+					addresses = append(addresses, value)
+				}
+			}
+		}
+
+		gatewayListeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+		for _, l := range gatewayListeners {
+			listener, ok := l.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if len(sectionName) > 0 && listener["name"] != sectionName {
+				continue
+			}
+			hostname, _ := listener["hostname"].(string)
+			protocol, _ := listener["protocol"].(string)
+			secretName := ""
+			refs, _, _ := unstructured.NestedSlice(listener, "tls", "certificateRefs")
+			if len(refs) > 0 {
+				if ref, ok := refs[0].(map[string]interface{}); ok {
+					secretName, _ = ref["name"].(string)
+				}
+			}
+			listeners = append(listeners, gatewayListener{hostname: hostname, tls: protocol == "HTTPS", secretName: secretName, addresses: addresses})
+		}
+	}
+	return listeners
+}
+
+//Build urls for every hostname and path matched by HTTPRoute
+func (s *informationServiceServer) describeHttpRouteUrls(ctx context.Context, route *unstructured.Unstructured) []*v1.AccessUrl {
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	listeners := s.findRouteListeners(ctx, route)
+
+	paths := make([]string, 0)
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		matches, _, _ := unstructured.NestedSlice(rule, "matches")
+		for _, m := range matches {
+			if match, ok := m.(map[string]interface{}); ok {
+				if path, found, _ := unstructured.NestedString(match, "path", "value"); found {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	if len(paths) == 0 {
+		paths = append(paths, "/")
+	}
+
+	urls := make([]*v1.AccessUrl, 0)
+	for _, host := range hostnames {
+		var matched *gatewayListener
+		for i := range listeners {
+			if len(listeners[i].hostname) > 0 && !hostnameMatches(listeners[i].hostname, host) {
+				continue
+			}
+			//prefer HTTPS listener when route is attached to both
+			if matched == nil || (listeners[i].tls && !matched.tls) {
+				matched = &listeners[i]
+			}
+		}
+		if matched == nil {
+			matched = &gatewayListener{}
+		}
+		for _, path := range paths {
+			urls = append(urls, &v1.AccessUrl{
+				Url: buildAccessUrl(matched.tls, host, path),
+				Source: "HTTPRoute/" + route.GetName(),
+				Tls: matched.tls,
+				TlsSecretName: matched.secretName,
+				AddressAssigned: len(matched.addresses) > 0,
+				Addresses: matched.addresses,
+			})
+		}
+	}
+	return urls
+}
+
+//Retrieve HTTPRoutes belonging to instance, no routes are returned if Gateway API is not installed
+func (s *informationServiceServer) findInstanceHttpRoutes(ctx context.Context, namespace string, uid string) ([]unstructured.Unstructured, error) {
+	routes, err := s.dynAPI.Resource(httpRouteResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	matching := make([]unstructured.Unstructured, 0)
+	for _, route := range routes.Items {
+		if belongsToInstance(&route, uid) {
+			matching = append(matching, route)
+		}
+	}
+	return matching, nil
+}
+
+func (s *informationServiceServer) RetrieveAccessUrls(ctx context.Context, req *v1.InstanceRequest) (*v1.AccessUrlsResponse, error) {
+	logLine("> Entered RetrieveAccessUrls method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareAccessUrlsResponse(v1.Status_FAILED, namespaceNotFound, nil), err
+	}
+
+	urls := make([]*v1.AccessUrl, 0)
+
+	ingresses, err := findInstanceIngresses(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return prepareAccessUrlsResponse(v1.Status_FAILED, "Error while retrieving ingresses!", nil), err
+	}
+	for i := range ingresses {
+		urls = append(urls, describeIngressUrls(&ingresses[i])...)
+	}
+
+	routes, err := s.findInstanceHttpRoutes(ctx, depl.Namespace, depl.Uid)
+	if err != nil {
+		return prepareAccessUrlsResponse(v1.Status_FAILED, "Error while retrieving HTTP routes!", nil), err
+	}
+	for i := range routes {
+		urls = append(urls, s.describeHttpRouteUrls(ctx, &routes[i])...)
+	}
+
+	if len(urls) == 0 {
+		return prepareAccessUrlsResponse(v1.Status_FAILED, "No ingress or HTTP route found!", nil), status.Errorf(codes.NotFound, "no ingress or HTTP route found for instance %s in namespace %s", depl.Uid, depl.Namespace)
+	}
+
+	logLine(fmt.Sprintf("< Found %d url(s)", len(urls)))
+	return prepareAccessUrlsResponse(v1.Status_OK, "", urls), nil
+}
@@ -0,0 +1,116 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func findAccessUrl(urls []*v1.AccessUrl, url string) *v1.AccessUrl {
+	for _, u := range urls {
+		if u.Url == url {
+			return u
+		}
+	}
+	return nil
+}
+
+func TestInformationServiceServer_RetrieveAccessUrls(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind": "Gateway",
+		"metadata": map[string]interface{}{"name": "shared-gateway", "namespace": "gateways"},
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"name": "http", "protocol": "HTTP", "port": int64(80)},
+				map[string]interface{}{"name": "https", "protocol": "HTTPS", "port": int64(443), "hostname": "*.nmaas.example.com",
+					"tls": map[string]interface{}{"certificateRefs": []interface{}{map[string]interface{}{"name": "wildcard-tls"}}}},
+			},
+		},
+		"status": map[string]interface{}{
+			"addresses": []interface{}{map[string]interface{}{"type": "IPAddress", "value": "192.0.2.20"}},
+		},
+	}}
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind": "HTTPRoute",
+		"metadata": map[string]interface{}{"name": "test-uid-api", "namespace": "test-namespace", "labels": map[string]interface{}{instanceLabel: "test-uid"}},
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{map[string]interface{}{"name": "shared-gateway", "namespace": "gateways"}},
+			"hostnames": []interface{}{"api.nmaas.example.com"},
+			"rules": []interface{}{map[string]interface{}{
+				"matches": []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": "/v2"}}},
+			}},
+		},
+	}}
+	dynClient := newFakeDynamicClient(route)
+	//created explicitly since fake client guesses wrong resource name for seeded Gateway objects
+	_, _ = dynClient.Resource(gatewayResource).Namespace("gateways").Create(context.Background(), gateway, metav1.CreateOptions{})
+	server := NewInformationServiceServer(client, dynClient)
+
+	//Fail on API version check
+	res, err := server.RetrieveAccessUrls(context.Background(), &illegal_req)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	freq := v1.InstanceRequest{Api: apiVersion, Deployment: &fake_ns_inst}
+	res, err = server.RetrieveAccessUrls(context.Background(), &freq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//create mock ingress with TLS and two paths, not yet assigned an address
+	ingress := networkingv1.Ingress{}
+	ingress.Name = "test-uid"
+	ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{"tool.example.com"}, SecretName: "test-uid-tls"}}
+	ingress.Spec.Rules = []networkingv1.IngressRule{
+		{Host: "tool.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+			Paths: []networkingv1.HTTPIngressPath{{Path: "/"}, {Path: "/grafana"}},
+		}}},
+		{Host: "plain.example.com"},
+	}
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").Create(context.Background(), &ingress, metav1.CreateOptions{})
+
+	//Pass
+	res, err = server.RetrieveAccessUrls(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK || len(res.Urls) != 4 {
+		t.Fatal(err)
+	}
+
+	url := findAccessUrl(res.Urls, "https://tool.example.com/grafana")
+	if url == nil || !url.Tls || url.TlsSecretName != "test-uid-tls" || url.AddressAssigned || url.Source != "Ingress/test-uid" {
+		t.Fail()
+	}
+	if url = findAccessUrl(res.Urls, "http://plain.example.com/"); url == nil || url.Tls {
+		t.Fail()
+	}
+
+	url = findAccessUrl(res.Urls, "https://api.nmaas.example.com/v2")
+	if url == nil || !url.Tls || url.TlsSecretName != "wildcard-tls" || !url.AddressAssigned || url.Addresses[0] != "192.0.2.20" || url.Source != "HTTPRoute/test-uid-api" {
+		t.Fail()
+	}
+
+	//Address is reported once assigned by ingress controller
+	ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.10.1.1"}}
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").UpdateStatus(context.Background(), &ingress, metav1.UpdateOptions{})
+
+	res, err = server.RetrieveAccessUrls(context.Background(), &req)
+	url = findAccessUrl(res.Urls, "https://tool.example.com/")
+	if err != nil || url == nil || !url.AddressAssigned || url.Addresses[0] != "10.10.1.1" {
+		t.Fail()
+	}
+}
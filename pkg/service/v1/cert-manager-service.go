@@ -0,0 +1,213 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	certManagerGroup = "cert-manager.io"
+	issuerKind = "Issuer"
+	clusterIssuerKind = "ClusterIssuer"
+)
+
+var certificateResource = schema.GroupVersionResource{Group: certManagerGroup, Version: "v1", Resource: "certificates"}
+
+func getTlsSecretName(uid string) string {
+	return uid + "-tls"
+}
+
+//Prepare certificate status response
+func prepareCertificateStatusResponse(status v1.Status, message string, ready bool, reason string, secretName string) *v1.CertificateStatusResponse {
+	return &v1.CertificateStatusResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Ready: ready,
+		Reason: reason,
+		SecretName: secretName,
+	}
+}
+
+//Prepare certificate status response for certificates stored directly in TLS secret
+func prepareSecretCertificateStatusResponse(secret *apiv1.Secret, now time.Time) *v1.CertificateStatusResponse {
+	reason := "Imported"
+	if isInternalCaCertificate(secret) {
+		reason = "IssuedByInternalCA"
+	}
+
+	cert, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+	if err != nil {
+		return prepareCertificateStatusResponse(v1.Status_FAILED, "Could not parse certificate", false, reason, secret.Name)
+	}
+	if now.After(cert.NotAfter) {
+		return prepareCertificateStatusResponse(v1.Status_FAILED, "Certificate has expired", false, "Expired", secret.Name)
+	}
+	return prepareCertificateStatusResponse(v1.Status_OK, "Certificate is up to date and has not expired", true, reason, secret.Name)
+}
+
+//Build cert-manager Certificate spec for given instance hostnames and issuer
+func prepareCertificateSpec(uid string, secretName string, hostnames []string, issuer string, kind string) map[string]interface{} {
+	dnsNames := make([]interface{}, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		dnsNames = append(dnsNames, hostname)
+	}
+
+	return map[string]interface{}{
+		"secretName": secretName,
+		"commonName": hostnames[0],
+		"dnsNames": dnsNames,
+		"issuerRef": map[string]interface{}{
+			"name": issuer,
+			"kind": kind,
+			"group": certManagerGroup,
+		},
+		//secret issued by cert-manager is labelled the same way as imported and internal CA ones
+		"secretTemplate": map[string]interface{}{
+			"labels": map[string]interface{}{instanceLabel: uid},
+		},
+	}
+}
+
+//Find condition of given type in cert-manager resource status
+func findCertificateCondition(cert *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(cert.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == conditionType {
+			return condition
+		}
+	}
+	return nil
+}
+
+func (s *certManagerServiceServer) CreateOrReplace(ctx context.Context, req *v1.CertificateRequest) (*v1.ServiceResponse, error) {
+	logLine("> Entered CertManager CreateOrReplace method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Instance
+
+	kind := req.IssuerKind
+	if len(kind) == 0 {
+		kind = issuerKind
+	}
+	if kind != issuerKind && kind != clusterIssuerKind && kind != internalCaIssuerKind {
+		return prepareResponse(v1.Status_FAILED, "Unsupported issuer kind"), status.Errorf(codes.InvalidArgument, "unsupported issuer kind '%s'", kind)
+	}
+
+	//internal CA does not need issuer name
+	if len(req.Hostnames) == 0 || (len(req.Issuer) == 0 && kind != internalCaIssuerKind) {
+		return prepareResponse(v1.Status_FAILED, "Hostnames and issuer are required"), status.Errorf(codes.InvalidArgument, "hostnames and issuer are required")
+	}
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, namespaceNotFound), err
+	}
+
+	name := getTlsSecretName(depl.Uid)
+
+	//imported certificates must not be overwritten by cert-manager
+	secret, err := s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil && isImportedCertificate(secret) {
+		return prepareResponse(v1.Status_FAILED, "Secret holds an imported certificate"), status.Errorf(codes.FailedPrecondition, "secret %s holds an imported certificate", name)
+	}
+
+	if kind == internalCaIssuerKind {
+		return s.issueFromInternalCa(ctx, depl, req.Hostnames)
+	}
+
+	spec := prepareCertificateSpec(depl.Uid, name, req.Hostnames, req.Issuer, kind)
+	certificates := s.dynAPI.Resource(certificateResource).Namespace(depl.Namespace)
+
+	cert, err := certificates.Get(ctx, name, metav1.GetOptions{})
+	//Certificate does not exist, we have to create it
+	if err != nil {
+		cert = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": certManagerGroup + "/v1",
+			"kind": "Certificate",
+			"metadata": map[string]interface{}{
+				"name": name,
+				"namespace": depl.Namespace,
+				"labels": map[string]interface{}{instanceLabel: depl.Uid},
+			},
+			"spec": spec,
+		}}
+
+		logLine(fmt.Sprintf("Creating certificate %s issued by %s %s", name, kind, req.Issuer))
+		_, err = certificates.Create(ctx, cert, metav1.CreateOptions{})
+		if err != nil {
+			return prepareResponse(v1.Status_FAILED, "Error while creating certificate!"), err
+		}
+		return prepareResponse(v1.Status_OK, "Certificate created successfully"), nil
+	}
+
+	logLine(fmt.Sprintf("Updating certificate %s issued by %s %s", name, kind, req.Issuer))
+	cert.Object["spec"] = spec
+	_, err = certificates.Update(ctx, cert, metav1.UpdateOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Error while updating certificate!"), err
+	}
+	return prepareResponse(v1.Status_OK, "Certificate updated successfully"), nil
+}
+
+func (s *certManagerServiceServer) GetStatus(ctx context.Context, req *v1.InstanceRequest) (*v1.CertificateStatusResponse, error) {
+	logLine("> Entered CertManager GetStatus method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareCertificateStatusResponse(v1.Status_FAILED, namespaceNotFound, false, "", ""), err
+	}
+
+	cert, err := s.dynAPI.Resource(certificateResource).Namespace(depl.Namespace).Get(ctx, getTlsSecretName(depl.Uid), metav1.GetOptions{})
+	if err != nil {
+		//certificates not managed by cert-manager are described by the secret itself
+		secret, secretErr := s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, getTlsSecretName(depl.Uid), metav1.GetOptions{})
+		if secretErr == nil && (isInternalCaCertificate(secret) || isImportedCertificate(secret)) {
+			return prepareSecretCertificateStatusResponse(secret, time.Now()), nil
+		}
+		return prepareCertificateStatusResponse(v1.Status_FAILED, "Certificate not found!", false, "", ""), err
+	}
+
+	secretName, _, _ := unstructured.NestedString(cert.Object, "spec", "secretName")
+
+	ready := findCertificateCondition(cert, "Ready")
+	if ready == nil {
+		return prepareCertificateStatusResponse(v1.Status_PENDING, "Waiting for certificate", false, "", secretName), nil
+	}
+
+	reason, _ := ready["reason"].(string)
+	message, _ := ready["message"].(string)
+	if ready["status"] == "True" {
+		return prepareCertificateStatusResponse(v1.Status_OK, message, true, reason, secretName), nil
+	}
+
+	//cert-manager reports failed issuance attempts on the Issuing condition
+	issuing := findCertificateCondition(cert, "Issuing")
+	if issuing != nil && issuing["status"] == "False" && issuing["reason"] == "Failed" {
+		issuingMessage, _ := issuing["message"].(string)
+		return prepareCertificateStatusResponse(v1.Status_FAILED, issuingMessage, false, "Failed", secretName), nil
+	}
+	return prepareCertificateStatusResponse(v1.Status_PENDING, message, false, reason, secretName), nil
+}
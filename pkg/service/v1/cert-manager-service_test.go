@@ -0,0 +1,158 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{
+		certificateResource: "CertificateList",
+		httpRouteResource: "HTTPRouteList",
+		gatewayResource: "GatewayList",
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+func TestCertManagerServiceServer_CreateOrReplace(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	dynClient := newFakeDynamicClient()
+	server := NewCertManagerServiceServer(client, dynClient, "nmaas-system")
+
+	//Fail on API version check
+	illreq := v1.CertificateRequest{Api: "dummy", Instance: &inst}
+	res, err := server.CreateOrReplace(context.Background(), &illreq)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on missing hostnames
+	certReq := v1.CertificateRequest{Api: apiVersion, Instance: &inst, Issuer: "letsencrypt"}
+	res, err = server.CreateOrReplace(context.Background(), &certReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Fail on unsupported issuer kind
+	certReq = v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: []string{"tool.example.com"}, Issuer: "letsencrypt", IssuerKind: "Unknown"}
+	res, err = server.CreateOrReplace(context.Background(), &certReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	certReq = v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: []string{"tool.example.com"}, Issuer: "letsencrypt", IssuerKind: clusterIssuerKind}
+	res, err = server.CreateOrReplace(context.Background(), &certReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Should create new certificate
+	res, err = server.CreateOrReplace(context.Background(), &certReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	cert, err := dynClient.Resource(certificateResource).Namespace("test-namespace").Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretName, _, _ := unstructured.NestedString(cert.Object, "spec", "secretName")
+	kind, _, _ := unstructured.NestedString(cert.Object, "spec", "issuerRef", "kind")
+	if secretName != "test-uid-tls" || kind != clusterIssuerKind {
+		t.Fail()
+	}
+	label, _, _ := unstructured.NestedString(cert.Object, "spec", "secretTemplate", "labels", instanceLabel)
+	if label != "test-uid" {
+		t.Fail()
+	}
+
+	//Should update existing certificate
+	certReq.Hostnames = []string{"tool.example.com", "www.tool.example.com"}
+	res, err = server.CreateOrReplace(context.Background(), &certReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	cert, _ = dynClient.Resource(certificateResource).Namespace("test-namespace").Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	dnsNames, _, _ := unstructured.NestedStringSlice(cert.Object, "spec", "dnsNames")
+	if len(dnsNames) != 2 {
+		t.Fail()
+	}
+}
+
+func TestCertManagerServiceServer_GetStatus(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	dynClient := newFakeDynamicClient()
+	server := NewCertManagerServiceServer(client, dynClient, "nmaas-system")
+
+	//Fail on API version check
+	res, err := server.GetStatus(context.Background(), &illegal_req)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	freq := v1.InstanceRequest{Api: apiVersion, Deployment: &fake_ns_inst}
+	res, err = server.GetStatus(context.Background(), &freq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing certificate
+	res, err = server.GetStatus(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Pending while certificate has no conditions
+	certReq := v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: []string{"tool.example.com"}, Issuer: "letsencrypt"}
+	_, _ = server.CreateOrReplace(context.Background(), &certReq)
+	res, err = server.GetStatus(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_PENDING || res.Ready || res.SecretName != "test-uid-tls" {
+		t.Fail()
+	}
+
+	//Failed issuance
+	certificates := dynClient.Resource(certificateResource).Namespace("test-namespace")
+	cert, _ := certificates.Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	_ = unstructured.SetNestedSlice(cert.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "False", "reason": "DoesNotExist", "message": "Issuing certificate as Secret does not exist"},
+		map[string]interface{}{"type": "Issuing", "status": "False", "reason": "Failed", "message": "ACME challenge failed"},
+	}, "status", "conditions")
+	_, _ = certificates.Update(context.Background(), cert, metav1.UpdateOptions{})
+
+	res, err = server.GetStatus(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_FAILED || res.Reason != "Failed" || res.Message != "ACME challenge failed" {
+		t.Fail()
+	}
+
+	//Ready certificate
+	_ = unstructured.SetNestedSlice(cert.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "True", "reason": "Ready", "message": "Certificate is up to date and has not expired"},
+	}, "status", "conditions")
+	_, _ = certificates.Update(context.Background(), cert, metav1.UpdateOptions{})
+
+	res, err = server.GetStatus(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK || !res.Ready || res.Reason != "Ready" {
+		t.Fail()
+	}
+}
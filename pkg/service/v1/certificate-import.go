@@ -0,0 +1,177 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	certificateSourceAnnotation = "janitor.nmaas.eu/certificate-source"
+	certificateSourceImported = "imported"
+)
+
+//Check if TLS secret holds a certificate uploaded by the user instead of one issued by cert-manager
+func isImportedCertificate(secret *apiv1.Secret) bool {
+	return secret.Annotations[certificateSourceAnnotation] == certificateSourceImported
+}
+
+//Parse all certificates from PEM encoded chain, leaf certificate first
+func parseCertificateChain(chain []byte) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0)
+	for {
+		var block *pem.Block
+		block, chain = pem.Decode(chain)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid certificate in chain: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "no certificate found in chain")
+	}
+	return certs, nil
+}
+
+//Verify that certificate chain and private key match, that the chain is trusted and covers all hostnames.
+//A self-signed root included at the end of the chain is trusted explicitly to support institutional CAs.
+func validateCertificateImport(chainPem []byte, keyPem []byte, hostnames []string) error {
+	if _, err := tls.X509KeyPair(chainPem, keyPem); err != nil {
+		return status.Errorf(codes.InvalidArgument, "private key does not match certificate: %v", err)
+	}
+
+	chain, err := parseCertificateChain(chainPem)
+	if err != nil {
+		return err
+	}
+
+	leaf := chain[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	last := chain[len(chain) - 1]
+	if bytes.Equal(last.RawIssuer, last.RawSubject) && last.CheckSignature(last.SignatureAlgorithm, last.RawTBSCertificate, last.Signature) == nil {
+		roots.AddCert(last)
+	}
+
+	opts := x509.VerifyOptions{
+		Intermediates: intermediates,
+		Roots: roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if _, err = leaf.Verify(opts); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid certificate chain: %v", err)
+	}
+
+	for _, hostname := range hostnames {
+		if err = leaf.VerifyHostname(hostname); err != nil {
+			return status.Errorf(codes.InvalidArgument, "certificate not valid for hostname '%s'", hostname)
+		}
+	}
+	return nil
+}
+
+func (s *certManagerServiceServer) ImportCertificate(ctx context.Context, req *v1.ImportCertificateRequest) (*v1.ServiceResponse, error) {
+	logLine("> Entered ImportCertificate method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Instance
+
+	if len(req.Hostnames) == 0 {
+		return prepareResponse(v1.Status_FAILED, "Hostnames are required"), status.Errorf(codes.InvalidArgument, "hostnames are required")
+	}
+
+	chainPem := []byte(req.CertificateChain)
+	keyPem := []byte(req.PrivateKey)
+	if err := validateCertificateImport(chainPem, keyPem, req.Hostnames); err != nil {
+		return prepareResponse(v1.Status_FAILED, "Certificate validation failed"), err
+	}
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, namespaceNotFound), err
+	}
+
+	secretName := getTlsSecretName(depl.Uid)
+
+	//stop cert-manager from re-issuing certificate into the imported secret
+	s.removeCertManagerCertificate(ctx, depl.Namespace, secretName, "imported one")
+
+	annotations := map[string]string{certificateSourceAnnotation: certificateSourceImported}
+	data := map[string][]byte{
+		apiv1.TLSCertKey: chainPem,
+		apiv1.TLSPrivateKeyKey: keyPem,
+	}
+	if err = writeInstanceTlsSecret(ctx, s.kubeAPI, depl.Namespace, depl.Uid, nil, annotations, data); err != nil {
+		return prepareResponse(v1.Status_FAILED, "Error while storing secret!"), err
+	}
+	return prepareResponse(v1.Status_OK, "Certificate imported successfully"), nil
+}
+
+//Delete cert-manager certificate of given name so that it does not overwrite certificate stored by the janitor
+func (s *certManagerServiceServer) removeCertManagerCertificate(ctx context.Context, namespace string, name string, replacement string) {
+	err := s.dynAPI.Resource(certificateResource).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err == nil {
+		logLine(fmt.Sprintf("Removed cert-manager certificate %s replaced by %s", name, replacement))
+	}
+}
+
+//Create or update TLS secret of instance, labelled with the instance uid and given additional labels
+func writeInstanceTlsSecret(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, uid string, labels map[string]string, annotations map[string]string, data map[string][]byte) error {
+	secretName := getTlsSecretName(uid)
+	secret := &apiv1.Secret{}
+	secret.SetNamespace(namespace)
+	secret.SetName(secretName)
+	secret.SetLabels(map[string]string{instanceLabel: uid})
+	for key, value := range labels {
+		secret.Labels[key] = value
+	}
+	secret.SetAnnotations(annotations)
+	secret.Type = apiv1.SecretTypeTLS
+	secret.Data = data
+
+	existing, err := kubeAPI.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err == nil && existing.Type == apiv1.SecretTypeTLS {
+		secret.ResourceVersion = existing.ResourceVersion
+		_, err = kubeAPI.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	}
+
+	//secret type is immutable, so secrets of other types have to be recreated
+	if err == nil {
+		err = kubeAPI.CoreV1().Secrets(namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = kubeAPI.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+	return err
+}
@@ -0,0 +1,94 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestValidateCertificateImport(t *testing.T) {
+	hostnames := []string{"tool.example.com"}
+	certPem, keyPem := generateTestCertificate(t, hostnames, time.Now().Add(24*time.Hour))
+	_, otherKeyPem := generateTestCertificate(t, hostnames, time.Now().Add(24*time.Hour))
+	expiredPem, expiredKeyPem := generateTestCertificate(t, hostnames, time.Now().Add(-time.Minute))
+
+	//Pass
+	if err := validateCertificateImport([]byte(certPem), []byte(keyPem), hostnames); err != nil {
+		t.Error(err)
+	}
+
+	//Fail on key mismatch
+	if err := validateCertificateImport([]byte(certPem), []byte(otherKeyPem), hostnames); err == nil {
+		t.Fail()
+	}
+
+	//Fail on hostname not covered by certificate
+	if err := validateCertificateImport([]byte(certPem), []byte(keyPem), []string{"other.example.com"}); err == nil {
+		t.Fail()
+	}
+
+	//Fail on expired certificate
+	if err := validateCertificateImport([]byte(expiredPem), []byte(expiredKeyPem), hostnames); err == nil {
+		t.Fail()
+	}
+}
+
+func TestCertManagerServiceServer_ImportCertificate(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewCertManagerServiceServer(client, newFakeDynamicClient(), "nmaas-system")
+
+	hostnames := []string{"tool.example.com"}
+	certPem, keyPem := generateTestCertificate(t, hostnames, time.Now().Add(24*time.Hour))
+
+	//Fail on API version check
+	illreq := v1.ImportCertificateRequest{Api: "dummy", Instance: &inst}
+	res, err := server.ImportCertificate(context.Background(), &illreq)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	impReq := v1.ImportCertificateRequest{Api: apiVersion, Instance: &inst, CertificateChain: certPem, PrivateKey: keyPem, Hostnames: hostnames}
+	res, err = server.ImportCertificate(context.Background(), &impReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//create mock opaque secret that has to be replaced
+	sec := corev1.Secret{}
+	sec.Name = "test-uid-tls"
+	_, _ = client.CoreV1().Secrets("test-namespace").Create(context.Background(), &sec, metav1.CreateOptions{})
+
+	//Pass
+	res, err = server.ImportCertificate(context.Background(), &impReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+
+	secret, err := client.CoreV1().Secrets("test-namespace").Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	if err != nil || secret.Type != corev1.SecretTypeTLS || !isImportedCertificate(secret) || string(secret.Data[corev1.TLSCertKey]) != certPem {
+		t.Fail()
+	}
+
+	//Pass on replacing imported certificate
+	res, err = server.ImportCertificate(context.Background(), &impReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	//Fail on requesting cert-manager certificate for imported secret
+	certReq := v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: hostnames, Issuer: "letsencrypt"}
+	res, err = server.CreateOrReplace(context.Background(), &certReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+}
@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	defaultExpiryThresholdDays = 30
+	certManagerCertificateNameAnnotation = "cert-manager.io/certificate-name"
+)
+
+//Prepare certificate info response
+func prepareCertificateInfoResponse(status v1.Status, message string, certificate *v1.CertificateInfo) *v1.CertificateInfoResponse {
+	return &v1.CertificateInfoResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Certificate: certificate,
+	}
+}
+
+//Prepare certificate list response
+func prepareCertificateListResponse(status v1.Status, message string, certificates []*v1.CertificateInfo) *v1.CertificateListResponse {
+	return &v1.CertificateListResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Certificates: certificates,
+	}
+}
+
+//Parse leaf certificate, which is the first certificate found in PEM data
+func parseLeafCertificate(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "no certificate found in PEM data")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+//Describe public key algorithm and size, e.g. RSA 2048 or ECDSA P-256
+func describeKeyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
+
+//Number of full days left until certificate expires, negative if already expired
+func daysToExpiry(cert *x509.Certificate, now time.Time) int64 {
+	return int64(math.Floor(cert.NotAfter.Sub(now).Hours() / 24))
+}
+
+func describeCertificate(cert *x509.Certificate, now time.Time) *v1.CertificateInfo {
+	ips := make([]string, 0, len(cert.IPAddresses))
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+
+	return &v1.CertificateInfo{
+		Subject: cert.Subject.String(),
+		DnsNames: cert.DNSNames,
+		IpAddresses: ips,
+		Issuer: cert.Issuer.String(),
+		NotBefore: cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter: cert.NotAfter.UTC().Format(time.RFC3339),
+		DaysToExpiry: daysToExpiry(cert, now),
+		KeyType: describeKeyType(cert),
+		SerialNumber: fmt.Sprintf("%x", cert.SerialNumber),
+	}
+}
+
+//Check if secret is the TLS secret of an instance, either labelled with the instance or named <uid>-tls and
+//written by cert-manager or janitor, as secrets created by ingress-shim or before labelling was added have no label
+func isInstanceTlsSecret(secret *apiv1.Secret) bool {
+	if secret.Type != apiv1.SecretTypeTLS {
+		return false
+	}
+	if uid, found := secret.Labels[instanceLabel]; found {
+		return secret.Name == getTlsSecretName(uid)
+	}
+	if !strings.HasSuffix(secret.Name, getTlsSecretName("")) {
+		return false
+	}
+	_, issued := secret.Annotations[certManagerCertificateNameAnnotation]
+	return issued || isImportedCertificate(secret) || isInternalCaCertificate(secret)
+}
+
+func (s *certManagerServiceServer) DescribeCertificate(ctx context.Context, req *v1.InstanceRequest) (*v1.CertificateInfoResponse, error) {
+	logLine("> Entered DescribeCertificate method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareCertificateInfoResponse(v1.Status_FAILED, namespaceNotFound, nil), err
+	}
+
+	secretName := getTlsSecretName(depl.Uid)
+	secret, err := s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return prepareCertificateInfoResponse(v1.Status_FAILED, "Secret not found!", nil), err
+	}
+
+	cert, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+	if err != nil {
+		return prepareCertificateInfoResponse(v1.Status_FAILED, "Could not parse certificate", nil), err
+	}
+
+	info := describeCertificate(cert, time.Now())
+	info.Namespace = depl.Namespace
+	info.SecretName = secretName
+
+	logLine(fmt.Sprintf("< Certificate in %s expires in %d days", secretName, info.DaysToExpiry))
+	return prepareCertificateInfoResponse(v1.Status_OK, "", info), nil
+}
+
+func (s *certManagerServiceServer) ListExpiringCertificates(ctx context.Context, req *v1.ExpiringCertificatesRequest) (*v1.CertificateListResponse, error) {
+	logLine("> Entered ListExpiringCertificates method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	threshold := int64(req.ThresholdDays)
+	if threshold <= 0 {
+		threshold = defaultExpiryThresholdDays
+	}
+
+	options := metav1.ListOptions{FieldSelector: "type=" + string(apiv1.SecretTypeTLS)}
+	secrets, err := s.kubeAPI.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, options)
+	if err != nil {
+		return prepareCertificateListResponse(v1.Status_FAILED, "Issue with collecting secrets", nil), err
+	}
+
+	now := time.Now()
+	expiring := make([]*v1.CertificateInfo, 0)
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !isInstanceTlsSecret(secret) {
+			continue
+		}
+
+		cert, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+		if err != nil {
+			logLine(fmt.Sprintf("Could not parse certificate from secret %s in namespace %s", secret.Name, secret.Namespace))
+			continue
+		}
+
+		if daysToExpiry(cert, now) > threshold {
+			continue
+		}
+
+		info := describeCertificate(cert, now)
+		info.Namespace = secret.Namespace
+		info.SecretName = secret.Name
+		expiring = append(expiring, info)
+	}
+
+	//soonest expiring first
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].DaysToExpiry < expiring[j].DaysToExpiry
+	})
+
+	logLine(fmt.Sprintf("< Found %d certificates expiring within %d days", len(expiring), threshold))
+	return prepareCertificateListResponse(v1.Status_OK, "", expiring), nil
+}
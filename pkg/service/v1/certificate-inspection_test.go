@@ -0,0 +1,148 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"math/big"
+	"testing"
+	"time"
+)
+
+//Generate self-signed certificate for given hostnames and return PEM encoded certificate and key
+func generateTestCertificate(t *testing.T, hostnames []string, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject: pkix.Name{CommonName: hostnames[0]},
+		DNSNames: hostnames,
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: notAfter,
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return string(certPem), string(keyPem)
+}
+
+func createTestTlsSecret(t *testing.T, client *testclient.Clientset, namespace string, name string, labels map[string]string, notAfter time.Time) {
+	certPem, keyPem := generateTestCertificate(t, []string{"tool.example.com", "www.tool.example.com"}, notAfter)
+	sec := corev1.Secret{}
+	sec.Name = name
+	sec.Labels = labels
+	sec.Type = corev1.SecretTypeTLS
+	sec.Data = map[string][]byte{corev1.TLSCertKey: []byte(certPem), corev1.TLSPrivateKeyKey: []byte(keyPem)}
+	_, _ = client.CoreV1().Secrets(namespace).Create(context.Background(), &sec, metav1.CreateOptions{})
+}
+
+func TestCertManagerServiceServer_DescribeCertificate(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewCertManagerServiceServer(client, newFakeDynamicClient(), "nmaas-system")
+
+	//Fail on API version check
+	res, err := server.DescribeCertificate(context.Background(), &illegal_req)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	freq := v1.InstanceRequest{Api: apiVersion, Deployment: &fake_ns_inst}
+	res, err = server.DescribeCertificate(context.Background(), &freq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing secret
+	res, err = server.DescribeCertificate(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Pass
+	createTestTlsSecret(t, client, "test-namespace", "test-uid-tls", nil, time.Now().Add(10*24*time.Hour + time.Hour))
+	res, err = server.DescribeCertificate(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+
+	cert := res.Certificate
+	if cert.DaysToExpiry != 10 || len(cert.DnsNames) != 2 || cert.KeyType != "ECDSA P-256" || cert.SerialNumber != "1092" {
+		t.Fail()
+	}
+	if cert.Subject != "CN=tool.example.com" || cert.SecretName != "test-uid-tls" || cert.Namespace != "test-namespace" {
+		t.Fail()
+	}
+}
+
+func TestCertManagerServiceServer_ListExpiringCertificates(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewCertManagerServiceServer(client, newFakeDynamicClient(), "nmaas-system")
+
+	//Fail on API version check
+	illreq := v1.ExpiringCertificatesRequest{Api: "dummy"}
+	res, err := server.ListExpiringCertificates(context.Background(), &illreq)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	createTestTlsSecret(t, client, "ns1", "first-tls", map[string]string{instanceLabel: "first"}, time.Now().Add(5*24*time.Hour))
+	createTestTlsSecret(t, client, "ns2", "second-tls", map[string]string{instanceLabel: "second"}, time.Now().Add(-24*time.Hour))
+	createTestTlsSecret(t, client, "ns2", "third-tls", map[string]string{instanceLabel: "third"}, time.Now().Add(90*24*time.Hour))
+	createTestTlsSecret(t, client, "ns2", "not-janitor-managed", nil, time.Now())
+	createTestTlsSecret(t, client, "ns2", "foreign-tls", nil, time.Now())
+	createTestTlsSecret(t, client, "ns2", "other-tls", map[string]string{instanceLabel: "another"}, time.Now())
+	//unlabelled secrets written by cert-manager ingress-shim or janitor
+	createTestTlsSecret(t, client, "ns1", "fourth-tls", nil, time.Now().Add(10*24*time.Hour))
+	createTestTlsSecret(t, client, "ns1", "fifth-tls", nil, time.Now().Add(20*24*time.Hour))
+	annotations := map[string]map[string]string{
+		"fourth-tls": {certManagerCertificateNameAnnotation: "fourth-tls"},
+		"fifth-tls": {certificateSourceAnnotation: certificateSourceImported},
+	}
+	for name, annotation := range annotations {
+		sec, _ := client.CoreV1().Secrets("ns1").Get(context.Background(), name, metav1.GetOptions{})
+		sec.Annotations = annotation
+		_, _ = client.CoreV1().Secrets("ns1").Update(context.Background(), sec, metav1.UpdateOptions{})
+	}
+
+	//Pass with default threshold
+	expReq := v1.ExpiringCertificatesRequest{Api: apiVersion}
+	res, err = server.ListExpiringCertificates(context.Background(), &expReq)
+	if err != nil || res.Status != v1.Status_OK || len(res.Certificates) != 4 {
+		t.Fatal(err)
+	}
+	if res.Certificates[0].SecretName != "second-tls" || res.Certificates[1].SecretName != "first-tls" || res.Certificates[2].SecretName != "fourth-tls" || res.Certificates[3].SecretName != "fifth-tls" {
+		t.Fail()
+	}
+
+	//Pass with custom threshold
+	expReq = v1.ExpiringCertificatesRequest{Api: apiVersion, ThresholdDays: 100}
+	res, err = server.ListExpiringCertificates(context.Background(), &expReq)
+	if err != nil || len(res.Certificates) != 5 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,177 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	defaultRenewalTimeout = 5 * time.Minute
+)
+
+var certificateRenewalPollInterval = 2 * time.Second
+
+//Prepare certificate renewal response
+func prepareCertificateRenewalResponse(status v1.Status, message string, serial string, previousSerial string) *v1.CertificateRenewalResponse {
+	return &v1.CertificateRenewalResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		SerialNumber: serial,
+		PreviousSerialNumber: previousSerial,
+	}
+}
+
+//Read serial number of the certificate stored in TLS secret, empty if secret or certificate is missing
+func (s *certManagerServiceServer) currentSerialNumber(ctx context.Context, namespace string, secretName string) string {
+	secret, err := s.kubeAPI.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	cert, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", cert.SerialNumber)
+}
+
+//Request re-issuance the same way cmctl renew does, by setting the Issuing condition on Certificate status
+func triggerCertificateIssuance(cert *unstructured.Unstructured, now time.Time) {
+	conditions, _, _ := unstructured.NestedSlice(cert.Object, "status", "conditions")
+
+	updated := make([]interface{}, 0, len(conditions) + 1)
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Issuing" {
+			continue
+		}
+		updated = append(updated, c)
+	}
+	updated = append(updated, map[string]interface{}{
+		"type": "Issuing",
+		"status": "True",
+		"reason": "ManuallyTriggered",
+		"message": "Certificate re-issuance manually triggered",
+		"lastTransitionTime": now.UTC().Format(time.RFC3339),
+	})
+
+	_ = unstructured.SetNestedSlice(cert.Object, updated, "status", "conditions")
+}
+
+func (s *certManagerServiceServer) Renew(req *v1.CertificateRenewalRequest, stream v1.CertManagerService_RenewServer) error {
+	logLine("> Entered Renew method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	depl := req.Instance
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, namespaceNotFound, "", ""))
+		return err
+	}
+
+	name := getTlsSecretName(depl.Uid)
+
+	secret, err := s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil && isInternalCaCertificate(secret) {
+		return s.renewFromInternalCa(ctx, depl, secret, stream)
+	}
+	if err == nil && isImportedCertificate(secret) {
+		_ = stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, "Secret holds an imported certificate", "", ""))
+		return status.Errorf(codes.FailedPrecondition, "secret %s holds an imported certificate", name)
+	}
+
+	certificates := s.dynAPI.Resource(certificateResource).Namespace(depl.Namespace)
+	cert, err := certificates.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, "Certificate not found!", "", ""))
+		return err
+	}
+
+	previousSerial := s.currentSerialNumber(ctx, depl.Namespace, name)
+
+	issuing := findCertificateCondition(cert, "Issuing")
+	if issuing == nil || issuing["status"] != "True" {
+		logLine(fmt.Sprintf("Triggering re-issuance of certificate %s in namespace %s", name, depl.Namespace))
+		triggerCertificateIssuance(cert, time.Now())
+		_, err = certificates.UpdateStatus(ctx, cert, metav1.UpdateOptions{})
+		if err != nil {
+			_ = stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, "Error while triggering certificate issuance!", "", previousSerial))
+			return err
+		}
+	}
+
+	err = stream.Send(prepareCertificateRenewalResponse(v1.Status_PENDING, "Certificate re-issuance triggered", "", previousSerial))
+	if err != nil {
+		return err
+	}
+
+	timeout := defaultRenewalTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(certificateRenewalPollInterval)
+	defer ticker.Stop()
+
+	lastMessage := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			logLine(fmt.Sprintf("< Timed out waiting for renewal of certificate %s", name))
+			return stream.Send(prepareCertificateRenewalResponse(v1.Status_PENDING, "Timed out waiting for certificate issuance", "", previousSerial))
+		case <-ticker.C:
+		}
+
+		cert, err = certificates.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			_ = stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, "Certificate not found!", "", previousSerial))
+			return err
+		}
+
+		issuing = findCertificateCondition(cert, "Issuing")
+		if issuing != nil && issuing["status"] == "False" && issuing["reason"] == "Failed" {
+			message, _ := issuing["message"].(string)
+			return stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, message, "", previousSerial))
+		}
+
+		ready := findCertificateCondition(cert, "Ready")
+		if (issuing == nil || issuing["status"] != "True") && ready != nil && ready["status"] == "True" {
+			serial := s.currentSerialNumber(ctx, depl.Namespace, name)
+			logLine(fmt.Sprintf("< Certificate %s re-issued with serial number %s", name, serial))
+			return stream.Send(prepareCertificateRenewalResponse(v1.Status_OK, "Certificate re-issued successfully", serial, previousSerial))
+		}
+
+		//report progress only when cert-manager has something new to say
+		message := "Waiting for certificate issuance"
+		if issuing != nil {
+			if m, ok := issuing["message"].(string); ok && len(m) > 0 {
+				message = m
+			}
+		}
+		if message != lastMessage {
+			lastMessage = message
+			err = stream.Send(prepareCertificateRenewalResponse(v1.Status_PENDING, message, "", previousSerial))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestCertManagerServiceServer_Renew(t *testing.T) {
+	previousInterval := certificateRenewalPollInterval
+	t.Cleanup(func() { certificateRenewalPollInterval = previousInterval })
+	certificateRenewalPollInterval = 10 * time.Millisecond
+
+	client := testclient.NewSimpleClientset()
+	dynClient := newFakeDynamicClient()
+	server := NewCertManagerServiceServer(client, dynClient, "nmaas-system")
+
+	//Fail on API version check
+	illreq := v1.CertificateRenewalRequest{Api: "dummy", Instance: &inst}
+	err := server.Renew(&illreq, newFakeServerStream[v1.CertificateRenewalResponse]())
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	renReq := v1.CertificateRenewalRequest{Api: apiVersion, Instance: &inst, TimeoutSeconds: 5}
+	stream := newFakeServerStream[v1.CertificateRenewalResponse]()
+	err = server.Renew(&renReq, stream)
+	if err == nil || (<-stream.responses).Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing certificate
+	stream = newFakeServerStream[v1.CertificateRenewalResponse]()
+	err = server.Renew(&renReq, stream)
+	if err == nil || (<-stream.responses).Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock certificate and its secret
+	certReq := v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: []string{"tool.example.com"}, Issuer: "letsencrypt"}
+	_, _ = server.CreateOrReplace(context.Background(), &certReq)
+	createTestTlsSecret(t, client, "test-namespace", "test-uid-tls", nil, time.Now().Add(24*time.Hour))
+
+	stream = newFakeServerStream[v1.CertificateRenewalResponse]()
+	done := make(chan error)
+	go func() {
+		done <- server.Renew(&renReq, stream)
+	}()
+
+	res := <-stream.responses
+	if res.Status != v1.Status_PENDING || res.PreviousSerialNumber != "1092" {
+		t.Fail()
+	}
+
+	//re-issuance should be requested on certificate status
+	certificates := dynClient.Resource(certificateResource).Namespace("test-namespace")
+	cert, _ := certificates.Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	issuing := findCertificateCondition(cert, "Issuing")
+	if issuing == nil || issuing["status"] != "True" || issuing["reason"] != "ManuallyTriggered" {
+		t.Fail()
+	}
+
+	//simulate cert-manager issuing new certificate
+	secret, _ := client.CoreV1().Secrets("test-namespace").Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	secret.Data[corev1.TLSCertKey] = []byte(generateTestCaPem(t))
+	_, _ = client.CoreV1().Secrets("test-namespace").Update(context.Background(), secret, metav1.UpdateOptions{})
+	_ = unstructured.SetNestedSlice(cert.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "True", "reason": "Ready"},
+	}, "status", "conditions")
+	_, _ = certificates.UpdateStatus(context.Background(), cert, metav1.UpdateOptions{})
+
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	for res.Status == v1.Status_PENDING {
+		res = <-stream.responses
+	}
+	if res.Status != v1.Status_OK || res.SerialNumber != "1" || res.PreviousSerialNumber != "1092" {
+		t.Fail()
+	}
+}
+
+func TestCertManagerServiceServer_RenewFailure(t *testing.T) {
+	previousInterval := certificateRenewalPollInterval
+	t.Cleanup(func() { certificateRenewalPollInterval = previousInterval })
+	certificateRenewalPollInterval = 10 * time.Millisecond
+
+	client := testclient.NewSimpleClientset()
+	dynClient := newFakeDynamicClient()
+	server := NewCertManagerServiceServer(client, dynClient, "nmaas-system")
+
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	certReq := v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: []string{"tool.example.com"}, Issuer: "letsencrypt"}
+	_, _ = server.CreateOrReplace(context.Background(), &certReq)
+
+	//certificate with failed issuance in progress
+	certificates := dynClient.Resource(certificateResource).Namespace("test-namespace")
+	cert, _ := certificates.Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	_ = unstructured.SetNestedSlice(cert.Object, []interface{}{
+		map[string]interface{}{"type": "Issuing", "status": "False", "reason": "Failed", "message": "ACME challenge failed"},
+	}, "status", "conditions")
+	_, _ = certificates.UpdateStatus(context.Background(), cert, metav1.UpdateOptions{})
+
+	stream := newFakeServerStream[v1.CertificateRenewalResponse]()
+	done := make(chan error)
+	go func() {
+		done <- server.Renew(&v1.CertificateRenewalRequest{Api: apiVersion, Instance: &inst, TimeoutSeconds: 5}, stream)
+	}()
+
+	//first response confirms trigger
+	<-stream.responses
+
+	cert, _ = certificates.Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	_ = unstructured.SetNestedSlice(cert.Object, []interface{}{
+		map[string]interface{}{"type": "Issuing", "status": "False", "reason": "Failed", "message": "ACME challenge failed again"},
+	}, "status", "conditions")
+	_, _ = certificates.UpdateStatus(context.Background(), cert, metav1.UpdateOptions{})
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	res := <-stream.responses
+	for res.Status == v1.Status_PENDING {
+		res = <-stream.responses
+	}
+	if res.Status != v1.Status_FAILED || res.Message != "ACME challenge failed again" {
+		t.Fail()
+	}
+}
@@ -9,13 +9,16 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"log"
+	"math"
 	"math/rand"
 	"strings"
 	"fmt"
-	"bytes"
-	"io"
+	"encoding/json"
+	"time"
 
 	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
 	"github.com/johnaoss/htpasswd/apr1"
@@ -37,6 +40,8 @@ type basicAuthServiceServer struct {
 
 type certManagerServiceServer struct {
 	kubeAPI kubernetes.Interface
+	dynAPI dynamic.Interface
+	ca *internalCA
 }
 
 type readinessServiceServer struct {
@@ -45,10 +50,12 @@ type readinessServiceServer struct {
 
 type informationServiceServer struct {
 	kubeAPI kubernetes.Interface
+	dynAPI dynamic.Interface
 }
 
 type podServiceServer struct {
 	kubeAPI kubernetes.Interface
+	newExecutor podExecutorFactory
 }
 
 type namespaceServiceServer struct {
@@ -63,20 +70,20 @@ func NewBasicAuthServiceServer(kubeAPI kubernetes.Interface) v1.BasicAuthService
 	return &basicAuthServiceServer{kubeAPI: kubeAPI}
 }
 
-func NewCertManagerServiceServer(kubeAPI kubernetes.Interface) v1.CertManagerServiceServer {
-	return &certManagerServiceServer{kubeAPI: kubeAPI}
+func NewCertManagerServiceServer(kubeAPI kubernetes.Interface, dynAPI dynamic.Interface, caNamespace string) v1.CertManagerServiceServer {
+	return &certManagerServiceServer{kubeAPI: kubeAPI, dynAPI: dynAPI, ca: newInternalCA(kubeAPI, caNamespace)}
 }
 
 func NewReadinessServiceServer(kubeAPI kubernetes.Interface) v1.ReadinessServiceServer {
 	return &readinessServiceServer{kubeAPI: kubeAPI}
 }
 
-func NewInformationServiceServer(kubeAPI kubernetes.Interface) v1.InformationServiceServer {
-	return &informationServiceServer{kubeAPI: kubeAPI}
+func NewInformationServiceServer(kubeAPI kubernetes.Interface, dynAPI dynamic.Interface) v1.InformationServiceServer {
+	return &informationServiceServer{kubeAPI: kubeAPI, dynAPI: dynAPI}
 }
 
-func NewPodServiceServer(kubeAPI kubernetes.Interface) v1.PodServiceServer {
-	return &podServiceServer{kubeAPI: kubeAPI}
+func NewPodServiceServer(kubeAPI kubernetes.Interface, config *rest.Config) v1.PodServiceServer {
+	return &podServiceServer{kubeAPI: kubeAPI, newExecutor: newPodExecutor(kubeAPI, config)}
 }
 
 func NewNamespaceServiceServer(kubeAPI kubernetes.Interface) v1.NamespaceServiceServer {
@@ -361,7 +368,7 @@ func (s *basicAuthServiceServer) PrepareSecretDataFromCredentials(credentials *v
 	}
 
 	resultMap := make(map[string][]byte)
-	resultMap["auth"] = []byte(hash)
+	resultMap[authSecretKey] = []byte(hash)
 
 	return resultMap, nil
 }
@@ -373,11 +380,19 @@ func (s *basicAuthServiceServer) PrepareSecretJsonFromCredentials(credentials *v
 		return nil, status.Errorf(codes.Internal, "Failed to execute htpasswd executable")
 	}
 
-	result := []byte("{\"data\": {\"auth\": \"")
-	result = append(result, base64.StdEncoding.EncodeToString([]byte(hash))...)
-	result = append(result, "\"}}"...)
+	//replacing the credentials also cancels any credential rotation in progress
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{credentialRotationLabel: nil},
+			"annotations": map[string]interface{}{credentialRotationExpiresAnnotation: nil},
+		},
+		"data": map[string]interface{}{
+			authSecretKey: base64.StdEncoding.EncodeToString([]byte(hash)),
+			previousAuthSecretKey: nil,
+		},
+	}
 
-	return result, nil
+	return json.Marshal(patch)
 }
 
 func getAuthSecretName(uid string) string {
@@ -405,7 +420,7 @@ func (s *basicAuthServiceServer) CreateOrReplace(ctx context.Context, req *v1.In
 
 	secretName := getAuthSecretName(depl.Uid)
 
-	_, err = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, secretName, metav1.GetOptions{})
+	existing, err := s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, secretName, metav1.GetOptions{})
 	//Secret does not exist, we have to create it
 	if err != nil {
 		//create secret
@@ -424,6 +439,22 @@ func (s *basicAuthServiceServer) CreateOrReplace(ctx context.Context, req *v1.In
 		}
 
 		return prepareResponse(v1.Status_OK, "Secret created successfully"), nil
+	} else if req.GracePeriodSeconds > 0 {
+		//keep previous credentials valid for the grace period
+		gracePeriod := time.Duration(req.GracePeriodSeconds) * time.Second
+		err = rotateAuthSecret(existing, req.Credentials, gracePeriod, time.Now())
+		if status.Code(err) == codes.InvalidArgument {
+			return prepareResponse(v1.Status_FAILED, "User name has to change to keep previous credentials valid during grace period!"), err
+		}
+		if err != nil {
+			return prepareResponse(v1.Status_FAILED, "Error while preparing secret!"), err
+		}
+
+		_, err = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Update(ctx, existing, metav1.UpdateOptions{})
+		if err != nil {
+			return prepareResponse(v1.Status_FAILED, "Error while updating secret!"), err
+		}
+		return prepareResponse(v1.Status_OK, fmt.Sprintf("Secret updated successfully, previous credentials remain valid for %s", gracePeriod)), nil
 	} else {
 		patch, err := s.PrepareSecretJsonFromCredentials(req.Credentials)
 		if err != nil {
@@ -483,7 +514,7 @@ func (s *certManagerServiceServer) DeleteIfExists(ctx context.Context, req *v1.I
 		return prepareResponse(v1.Status_FAILED, namespaceNotFound), err
 	}
 
-	secretName := depl.Uid + "-tls"
+	secretName := getTlsSecretName(depl.Uid)
 
 	//check if secret exist
 	_, err = s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, secretName, metav1.GetOptions{})
@@ -499,7 +530,7 @@ func (s *certManagerServiceServer) DeleteIfExists(ctx context.Context, req *v1.I
 	return prepareResponse(v1.Status_OK, "Secret deleted successfully"), nil
 }
 
-func (s *readinessServiceServer) CheckIfReady(ctx context.Context, req *v1.InstanceRequest) (*v1.ServiceResponse, error) {
+func (s *readinessServiceServer) CheckIfReady(ctx context.Context, req *v1.InstanceRequest) (*v1.ReadinessResponse, error) {
 	// check if the API version requested by client is supported by server
 	if err := checkAPI(req.Api, apiVersion); err != nil {
 		return nil, err
@@ -511,36 +542,11 @@ func (s *readinessServiceServer) CheckIfReady(ctx context.Context, req *v1.Insta
 	//check if given k8s namespace exists
 	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
 	if err != nil {
-		return prepareResponse(v1.Status_FAILED, namespaceNotFound), err
-	}
-
-	logLine("looking for deployment and checking its status")
-	dep, err := s.kubeAPI.AppsV1().Deployments(depl.Namespace).Get(ctx, depl.Uid, metav1.GetOptions{})
-	if err != nil {
-		logLine("deployment not found, looking for statefulset and checking its status")
-		sts, err2 := s.kubeAPI.AppsV1().StatefulSets(depl.Namespace).Get(ctx, depl.Uid, metav1.GetOptions{})
-		if err2 != nil {
-			logLine("statefulset not found as well")
-			return prepareResponse(v1.Status_FAILED, "Neither Deployment nor StatefulSet found!"), err2
-		} else {
-			logLine("statefulset found, verifying status")
-			if *sts.Spec.Replicas == sts.Status.ReadyReplicas {
-		        logLine("ready")
-				return prepareResponse(v1.Status_OK, "StatefulSet is ready"), nil
-			}
-			logLine("not yet ready")
-			return prepareResponse(v1.Status_PENDING, "Waiting for statefulset"), nil
-		}
-	} else {
-		logLine("deployment found, verifying status")
-		if *dep.Spec.Replicas == dep.Status.ReadyReplicas {
-		    logLine("ready")
-			return prepareResponse(v1.Status_OK, "Deployment is ready"), nil
-		}
-		logLine("not yet ready")
-		return prepareResponse(v1.Status_PENDING, "Waiting for deployment"), nil
+		return prepareReadinessResponse(v1.Status_FAILED, namespaceNotFound, nil), err
 	}
 
+	logLine("looking for instance workloads and checking their status")
+	return evaluateInstanceReadiness(ctx, s.kubeAPI, depl)
 }
 
 func (s *informationServiceServer) RetrieveServiceIp(ctx context.Context, req *v1.InstanceRequest) (*v1.InfoServiceResponse, error) {
@@ -625,25 +631,16 @@ func (s *podServiceServer) RetrievePodList(ctx context.Context, req *v1.Instance
 		return preparePodListResponse(v1.Status_FAILED, namespaceNotFound, nil), err
 	}
 
-    //collecting all pods from given namespace
-	logLine(fmt.Sprintf("Collecting pods from namespace %s", depl.Namespace))
-	allPods, err := s.kubeAPI.CoreV1().Pods(depl.Namespace).List(ctx, metav1.ListOptions{})
+    //collecting pods selected by instance workloads or labelled with the instance
+	logLine(fmt.Sprintf("Collecting pods of instance %s from namespace %s", depl.Uid, depl.Namespace))
+	pods, err := findInstancePods(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
 	if err != nil {
 		return preparePodListResponse(v1.Status_FAILED, "Issue with collecting pods", nil), err
 	}
 
-	matchingPods := make([]*v1.PodInfo, 0)
-
-    //filtering only those pods that match the deployment name
- 	logLine(fmt.Sprintf("Filtering pods by deployment %s", depl.Uid))
-	for _, pod := range allPods.Items {
-	    if (strings.HasPrefix(pod.Name, depl.Uid + "-")) {
-	        newPod := &v1.PodInfo{Name: pod.Name, DisplayName: pod.Name, Containers: make([]string, 0)}
-	        for container := range pod.Spec.Containers {
-                newPod.Containers = append(newPod.Containers, pod.Spec.Containers[container].Name)
-            }
-	        matchingPods = append(matchingPods, newPod)
-	    }
+	matchingPods := make([]*v1.PodInfo, 0, len(pods))
+	for i := range pods {
+		matchingPods = append(matchingPods, describePod(&pods[i]))
 	}
 
     logLine(fmt.Sprintf("< Found %d matching pods", len(matchingPods)))
@@ -673,15 +670,17 @@ func (s *podServiceServer) RetrievePodLogs(ctx context.Context, req *v1.PodReque
     }
 
     //collecting logs from given pod
-    var opts apiv1.PodLogOptions
-    if len(pod.Containers) == 0 {
-        opts = apiv1.PodLogOptions{}
-    } else {
-        opts = apiv1.PodLogOptions{Container: pod.Containers[0]}
+    opts, err := preparePodLogOptions(req)
+    if err != nil {
+        return preparePodLogsResponse(v1.Status_FAILED, "Invalid log options", nil), err
+    }
+    filter, err := newLogFilter(req.Filter)
+    if err != nil {
+        return preparePodLogsResponse(v1.Status_FAILED, "Invalid log filter", nil), err
     }
 	logLine(fmt.Sprintf("Collecting logs from pod/container %s/%s in namespace %s", pod.Name, opts.Container, depl.Namespace))
 
-	logsRequest := s.kubeAPI.CoreV1().Pods(depl.Namespace).GetLogs(pod.Name, &opts)
+	logsRequest := s.kubeAPI.CoreV1().Pods(depl.Namespace).GetLogs(pod.Name, opts)
 
     podLogs, err := logsRequest.Stream(ctx)
 	if err != nil {
@@ -689,15 +688,26 @@ func (s *podServiceServer) RetrievePodLogs(ctx context.Context, req *v1.PodReque
 	}
     defer podLogs.Close()
 
-    logBuffer := new(bytes.Buffer)
-    _, err = io.Copy(logBuffer, podLogs)
+	//lines are filtered while reading so that only accepted ones are kept in memory
+	//byte budget of followed streams is not applied here, size of response is bounded by limitBytes and tailLines
+	lines := make([]string, 0)
+	err = forwardLogLines(podLogs, pod.Name, opts.Container, math.MaxInt64, filter, func(line *v1.PodLogLine) error {
+		if line.Status != v1.Status_OK {
+			return nil
+		}
+		if len(line.Timestamp) > 0 {
+			lines = append(lines, line.Timestamp + " " + line.Line)
+		} else {
+			lines = append(lines, line.Line)
+		}
+		return nil
+	})
 	if err != nil {
-		return preparePodLogsResponse(v1.Status_FAILED, "Issue with copying data from stream to string", nil), err
+		return preparePodLogsResponse(v1.Status_FAILED, "Issue with reading logs", nil), err
 	}
-    logs := logBuffer.String()
 
-    logLine(fmt.Sprintf("< Returning %d characters", len(logs)))
-	return preparePodLogsResponse(v1.Status_OK, "", []string{logs}), err
+    logLine(fmt.Sprintf("< Returning %d lines", len(lines)))
+	return preparePodLogsResponse(v1.Status_OK, "", lines), err
 }
 
 func (s *namespaceServiceServer) CreateNamespace(ctx context.Context, req *v1.NamespaceRequest) (*v1.ServiceResponse, error) {
@@ -66,6 +66,9 @@ func TestReadinessServiceServer_CheckIfReady(t *testing.T) {
 	depl.Name = "test-uid"
 	q := int32(5)
 	depl.Spec.Replicas = &q
+	depl.Status.Replicas = q
+	depl.Status.UpdatedReplicas = q
+	depl.Status.AvailableReplicas = q
 	depl.Status.ReadyReplicas = q
 	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
 
@@ -129,7 +132,7 @@ func TestReadinessServiceServer_CheckIfReadyWithStatefulSet(t *testing.T) {
 
 func TestInformationServiceServer_RetrieveServiceIp(t *testing.T) {
 	client := testclient.NewSimpleClientset()
-	server := NewInformationServiceServer(client)
+	server := NewInformationServiceServer(client, newFakeDynamicClient())
 
 	//Fail on API version check
 	res, err := server.RetrieveServiceIp(context.Background(), &illegal_req)
@@ -200,7 +203,7 @@ func TestInformationServiceServer_RetrieveServiceIp(t *testing.T) {
 
 func TestInformationServiceServer_CheckServiceExists(t *testing.T) {
 	client := testclient.NewSimpleClientset()
-	server := NewInformationServiceServer(client)
+	server := NewInformationServiceServer(client, newFakeDynamicClient())
 
 	//Fail on API version check
 	res, err := server.CheckServiceExists(context.Background(), &illegal_req)
@@ -240,7 +243,7 @@ func TestInformationServiceServer_CheckServiceExists(t *testing.T) {
 
 func TestCertManagerServiceServer_DeleteIfExists(t *testing.T) {
 	client := testclient.NewSimpleClientset()
-	server := NewCertManagerServiceServer(client)
+	server := NewCertManagerServiceServer(client, newFakeDynamicClient(), "nmaas-system")
 
 	//Fail on API version check
 	res, err := server.DeleteIfExists(context.Background(), &illegal_req)
@@ -385,7 +388,7 @@ func TestConfigServiceServer_DeleteIfExists(t *testing.T) {
 
 func TestPodServiceServer_RetrievePodList(t *testing.T) {
 	client := testclient.NewSimpleClientset()
-	server := NewPodServiceServer(client)
+	server := NewPodServiceServer(client, nil)
 
 	//Fail on API version check
 	res, err := server.RetrievePodList(context.Background(), &illegal_req)
@@ -411,15 +414,18 @@ func TestPodServiceServer_RetrievePodList(t *testing.T) {
 		t.Fail()
 	}
 
-	//create mock pods (2 out of 3 should match the deployment name)
+	//create mock pods (2 out of 3 should match the instance label)
 	p1 := corev1.Pod{}
 	p1.Name = "test-uid-pod"
+	p1.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
 	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p1, metav1.CreateOptions{})
 	p2 := corev1.Pod{}
 	p2.Name = "test-uid-pod2"
+	p2.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
 	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p2, metav1.CreateOptions{})
     p3 := corev1.Pod{}
     p3.Name = "test-uid2-pod1"
+    p3.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid2"}
     _, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p3, metav1.CreateOptions{})
 
 	//Pass
@@ -431,7 +437,7 @@ func TestPodServiceServer_RetrievePodList(t *testing.T) {
 
 func TestPodServiceServer_RetrievePodLogs(t *testing.T) {
 	client := testclient.NewSimpleClientset()
-	server := NewPodServiceServer(client)
+	server := NewPodServiceServer(client, nil)
 
 	//Fail on namespace check
 	fPodReq := v1.PodRequest{Api:apiVersion, Pod:nil, Deployment:&fake_ns_inst}
@@ -464,6 +470,22 @@ func TestPodServiceServer_RetrievePodLogs(t *testing.T) {
 	if err != nil || res.Status != v1.Status_OK || len(res.Lines) != 1 {
 		t.Fail()
 	}
+
+	//Pass even if logs exceed byte budget of followed streams
+	previousBudget := podLogStreamByteBudget
+	t.Cleanup(func() { podLogStreamByteBudget = previousBudget })
+	podLogStreamByteBudget = 1
+	res, err = server.RetrievePodLogs(context.Background(), &podReq)
+	if err != nil || res.Status != v1.Status_OK || len(res.Lines) != 1 || res.Lines[0] != "fake logs" {
+		t.Fail()
+	}
+
+	//Fail on invalid log options
+	invalidReq := v1.PodRequest{Api:apiVersion, Pod:&pod, Deployment:&inst, SinceSeconds:60, SinceTime:"2024-03-01T10:00:00Z"}
+	res, err = server.RetrievePodLogs(context.Background(), &invalidReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
 }
 
 func TestNamespaceServiceServer_CreateNamespace(t *testing.T) {
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	authSecretKey = "auth"
+	previousAuthSecretKey = "previous-auth"
+	credentialRotationLabel = "janitor.nmaas.eu/credential-rotation"
+	credentialRotationPending = "pending"
+	credentialRotationExpiresAnnotation = "janitor.nmaas.eu/rotation-expires-at"
+)
+
+//Return the entry currently in use, which is always the first line of the htpasswd data
+func currentAuthEntry(secret *apiv1.Secret) string {
+	return strings.SplitN(string(secret.Data[authSecretKey]), "\n", 2)[0]
+}
+
+func isRotationPending(secret *apiv1.Secret) bool {
+	return secret.Labels[credentialRotationLabel] == credentialRotationPending
+}
+
+//Return the login of htpasswd entry
+func authEntryUser(entry string) string {
+	return strings.SplitN(entry, ":", 2)[0]
+}
+
+//Replace the current credentials while keeping the previous entry valid until the grace period expires.
+//Rotation state lives on the secret itself so that it survives janitor restarts.
+//nginx only checks the first htpasswd line with matching login, so the user has to change for both passwords to be accepted.
+func rotateAuthSecret(secret *apiv1.Secret, credentials *v1.Credentials, gracePeriod time.Duration, now time.Time) error {
+	previous := currentAuthEntry(secret)
+	if len(previous) > 0 && authEntryUser(previous) == credentials.User {
+		return status.Errorf(codes.InvalidArgument, "user %s has to change to keep previous credentials valid during grace period", credentials.User)
+	}
+
+	hash, err := aprHashCredentials(credentials.User, credentials.Password)
+	if err != nil {
+		return err
+	}
+
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	if len(previous) > 0 {
+		secret.Data[authSecretKey] = []byte(hash + "\n" + previous)
+		secret.Data[previousAuthSecretKey] = []byte(previous)
+	} else {
+		secret.Data[authSecretKey] = []byte(hash)
+		delete(secret.Data, previousAuthSecretKey)
+	}
+
+	if secret.Labels == nil {
+		secret.Labels = make(map[string]string)
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Labels[credentialRotationLabel] = credentialRotationPending
+	secret.Annotations[credentialRotationExpiresAnnotation] = now.Add(gracePeriod).UTC().Format(time.RFC3339)
+	return nil
+}
+
+//Drop the previous credentials once the grace period is over. Returns true if the secret was modified.
+func finishAuthSecretRotation(secret *apiv1.Secret, now time.Time) bool {
+	if !isRotationPending(secret) {
+		return false
+	}
+
+	expires, err := time.Parse(time.RFC3339, secret.Annotations[credentialRotationExpiresAnnotation])
+	if err == nil && now.Before(expires) {
+		return false
+	}
+
+	secret.Data[authSecretKey] = []byte(currentAuthEntry(secret))
+	delete(secret.Data, previousAuthSecretKey)
+	delete(secret.Labels, credentialRotationLabel)
+	delete(secret.Annotations, credentialRotationExpiresAnnotation)
+	return true
+}
+
+//Finish all credential rotations whose grace period has expired, returns the number of updated secrets
+func expireCredentialRotations(ctx context.Context, kubeAPI kubernetes.Interface, now time.Time) int {
+	selector := credentialRotationLabel + "=" + credentialRotationPending
+	secrets, err := kubeAPI.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		logLine(fmt.Sprintf("Could not retrieve secrets with pending credential rotation: %v", err))
+		return 0
+	}
+
+	updated := 0
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !finishAuthSecretRotation(secret, now) {
+			continue
+		}
+		logLine(fmt.Sprintf("Removing previous credentials from secret %s in namespace %s", secret.Name, secret.Namespace))
+		_, err = kubeAPI.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		if err != nil {
+			logLine(fmt.Sprintf("Error occurred while updating secret %s: %v", secret.Name, err))
+			continue
+		}
+		updated++
+	}
+	return updated
+}
+
+//RunCredentialRotationSweeper periodically removes expired previous credentials from basic auth secrets
+func RunCredentialRotationSweeper(ctx context.Context, kubeAPI kubernetes.Interface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expireCredentialRotations(ctx, kubeAPI, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	"github.com/johnaoss/htpasswd/apr1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Check credentials the way nginx does, only the first htpasswd line with matching login is considered
+func htpasswdAccepts(data string, user string, password string) bool {
+	for _, line := range strings.Split(data, "\n") {
+		login, hash, found := strings.Cut(line, ":")
+		if !found || login != user {
+			continue
+		}
+		parts := strings.Split(hash, "$")
+		if len(parts) != 4 {
+			return false
+		}
+		computed, err := apr1.Hash(password, parts[2])
+		return err == nil && computed == hash
+	}
+	return false
+}
+
+func TestBasicAuthServiceServer_CreateOrReplaceWithGracePeriod(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewBasicAuthServiceServer(client)
+
+	creds := v1.Credentials{User: "test-user", Password: "test-password"}
+	req := v1.InstanceCredentialsRequest{Api: apiVersion, Instance: &inst, Credentials: &creds}
+	res, err := server.CreateOrReplace(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	sec, _ := client.CoreV1().Secrets("test-namespace").Get(context.Background(), getAuthSecretName("test-uid"), metav1.GetOptions{})
+	original := string(sec.Data[authSecretKey])
+
+	//Rotate credentials keeping the previous ones valid
+	newCreds := v1.Credentials{User: "new-user", Password: "new-password"}
+	rotReq := v1.InstanceCredentialsRequest{Api: apiVersion, Instance: &inst, Credentials: &newCreds, GracePeriodSeconds: 60}
+	res, err = server.CreateOrReplace(context.Background(), &rotReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	sec, _ = client.CoreV1().Secrets("test-namespace").Get(context.Background(), getAuthSecretName("test-uid"), metav1.GetOptions{})
+	entries := strings.Split(string(sec.Data[authSecretKey]), "\n")
+	if len(entries) != 2 || entries[0] == original || entries[1] != original {
+		t.Fail()
+	}
+	if !isRotationPending(sec) || string(sec.Data[previousAuthSecretKey]) != original {
+		t.Fail()
+	}
+
+	//Both old and new credentials are accepted during the grace period
+	if !htpasswdAccepts(string(sec.Data[authSecretKey]), "test-user", "test-password") {
+		t.Fail()
+	}
+	if !htpasswdAccepts(string(sec.Data[authSecretKey]), "new-user", "new-password") {
+		t.Fail()
+	}
+
+	//Nothing to expire before the grace period ends
+	if expireCredentialRotations(context.Background(), client, time.Now()) != 0 {
+		t.Fail()
+	}
+
+	//Previous credentials are removed after the grace period
+	if expireCredentialRotations(context.Background(), client, time.Now().Add(2*time.Minute)) != 1 {
+		t.Fail()
+	}
+
+	sec, _ = client.CoreV1().Secrets("test-namespace").Get(context.Background(), getAuthSecretName("test-uid"), metav1.GetOptions{})
+	if string(sec.Data[authSecretKey]) != entries[0] || isRotationPending(sec) {
+		t.Fail()
+	}
+	if htpasswdAccepts(string(sec.Data[authSecretKey]), "test-user", "test-password") {
+		t.Fail()
+	}
+	if _, found := sec.Data[previousAuthSecretKey]; found {
+		t.Fail()
+	}
+}
+
+func TestBasicAuthServiceServer_CreateOrReplaceCancelsRotation(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewBasicAuthServiceServer(client)
+
+	creds := v1.Credentials{User: "test-user", Password: "test-password"}
+	req := v1.InstanceCredentialsRequest{Api: apiVersion, Instance: &inst, Credentials: &creds}
+	_, _ = server.CreateOrReplace(context.Background(), &req)
+
+	newCreds := v1.Credentials{User: "new-user", Password: "new-password"}
+	rotReq := v1.InstanceCredentialsRequest{Api: apiVersion, Instance: &inst, Credentials: &newCreds, GracePeriodSeconds: 60}
+	_, _ = server.CreateOrReplace(context.Background(), &rotReq)
+
+	//Hard replacement drops the previous credentials immediately
+	res, err := server.CreateOrReplace(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	sec, _ := client.CoreV1().Secrets("test-namespace").Get(context.Background(), getAuthSecretName("test-uid"), metav1.GetOptions{})
+	if strings.Contains(string(sec.Data[authSecretKey]), "\n") || isRotationPending(sec) {
+		t.Fail()
+	}
+	if _, found := sec.Data[previousAuthSecretKey]; found {
+		t.Fail()
+	}
+}
+
+func TestBasicAuthServiceServer_CreateOrReplaceWithGracePeriodSameUser(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewBasicAuthServiceServer(client)
+
+	creds := v1.Credentials{User: "test-user", Password: "test-password"}
+	req := v1.InstanceCredentialsRequest{Api: apiVersion, Instance: &inst, Credentials: &creds}
+	_, _ = server.CreateOrReplace(context.Background(), &req)
+
+	//Old password could not be accepted next to the new one for the same user
+	newCreds := v1.Credentials{User: "test-user", Password: "new-password"}
+	rotReq := v1.InstanceCredentialsRequest{Api: apiVersion, Instance: &inst, Credentials: &newCreds, GracePeriodSeconds: 60}
+	res, err := server.CreateOrReplace(context.Background(), &rotReq)
+	if status.Code(err) != codes.InvalidArgument || res.Status != v1.Status_FAILED || res.Message != "User name has to change to keep previous credentials valid during grace period!" {
+		t.Fail()
+	}
+
+	sec, _ := client.CoreV1().Secrets("test-namespace").Get(context.Background(), getAuthSecretName("test-uid"), metav1.GetOptions{})
+	if !htpasswdAccepts(string(sec.Data[authSecretKey]), "test-user", "test-password") || isRotationPending(sec) {
+		t.Fail()
+	}
+}
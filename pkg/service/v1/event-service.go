@@ -0,0 +1,401 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	defaultEventWatchTimeout = 15 * time.Minute
+)
+
+type eventServiceServer struct {
+	kubeAPI kubernetes.Interface
+}
+
+func NewEventServiceServer(kubeAPI kubernetes.Interface) v1.EventServiceServer {
+	return &eventServiceServer{kubeAPI: kubeAPI}
+}
+
+//Prepare instance events response
+func prepareInstanceEventsResponse(status v1.Status, message string, events []*v1.InstanceEvent) *v1.InstanceEventsResponse {
+	return &v1.InstanceEventsResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Events: events,
+	}
+}
+
+//Type and time filter of events given in request
+type eventFilter struct {
+	eventType string
+	since time.Time
+}
+
+func newEventFilter(req *v1.InstanceEventsRequest) (*eventFilter, error) {
+	filter := &eventFilter{eventType: req.Type}
+	if len(req.Type) > 0 && req.Type != apiv1.EventTypeNormal && req.Type != apiv1.EventTypeWarning {
+		return nil, status.Errorf(codes.InvalidArgument, "event type must be either %s or %s", apiv1.EventTypeNormal, apiv1.EventTypeWarning)
+	}
+	if req.SinceSeconds > 0 && len(req.SinceTime) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "only one of sinceSeconds and sinceTime can be set")
+	}
+	if req.SinceSeconds > 0 {
+		filter.since = time.Now().Add(-time.Duration(req.SinceSeconds) * time.Second)
+	}
+	if len(req.SinceTime) > 0 {
+		since, err := time.Parse(time.RFC3339, req.SinceTime)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "sinceTime %s is not a valid RFC3339 timestamp", req.SinceTime)
+		}
+		filter.since = since
+	}
+	return filter, nil
+}
+
+//Type is filtered by API server as well, time only here
+func (f *eventFilter) listOptions() metav1.ListOptions {
+	if len(f.eventType) == 0 {
+		return metav1.ListOptions{}
+	}
+	return metav1.ListOptions{FieldSelector: "type=" + f.eventType}
+}
+
+func (f *eventFilter) matches(event *apiv1.Event) bool {
+	if len(f.eventType) > 0 && event.Type != f.eventType {
+		return false
+	}
+	return f.since.IsZero() || !eventTime(event).Before(f.since)
+}
+
+//Objects belonging to instance keyed by kind and name, together with controllers whose children are named after them.
+//Objects already checked during watch and found not to belong to instance are remembered as foreign.
+type instanceObjects struct {
+	names map[string]bool
+	controllers map[string][]string
+	foreign map[string]bool
+}
+
+func (o *instanceObjects) ownsObject(kind string, name string) bool {
+	if o.names[kind + "/" + name] {
+		return true
+	}
+	for _, controller := range o.controllers[kind] {
+		if isGeneratedChildName(name, controller) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *instanceObjects) owns(event *apiv1.Event) bool {
+	return o.ownsObject(event.InvolvedObject.Kind, event.InvolvedObject.Name)
+}
+
+//Collect instance workloads with their replicasets and jobs, pods, persistent volume claims and ingresses
+func findInstanceObjects(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, uid string) (*instanceObjects, error) {
+	objects := &instanceObjects{names: make(map[string]bool), controllers: make(map[string][]string), foreign: make(map[string]bool)}
+
+	workloads, err := findInstanceWorkloads(ctx, kubeAPI, namespace, uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, workload := range workloads {
+		objects.names[workload.status.Kind + "/" + workload.status.Name] = true
+		switch workload.status.Kind {
+		case "Deployment":
+			objects.controllers["ReplicaSet"] = append(objects.controllers["ReplicaSet"], workload.status.Name)
+		case "CronJob":
+			objects.controllers["Job"] = append(objects.controllers["Job"], workload.status.Name)
+		}
+	}
+
+	pods, err := findInstancePods(ctx, kubeAPI, namespace, uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		objects.names["Pod/" + pod.Name] = true
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				objects.names["PersistentVolumeClaim/" + volume.PersistentVolumeClaim.ClaimName] = true
+			}
+		}
+	}
+
+	claims, err := kubeAPI.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, claim := range claims.Items {
+		if belongsToInstance(&claim, uid) {
+			objects.names["PersistentVolumeClaim/" + claim.Name] = true
+		}
+	}
+
+	ingresses, err := findInstanceIngresses(ctx, kubeAPI, namespace, uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, ingress := range ingresses {
+		objects.names["Ingress/" + ingress.Name] = true
+	}
+	return objects, nil
+}
+
+//Check if object created after instance objects were collected belongs to instance, by its labels or its controller.
+//Only kinds which are created while instance is running are checked, each of them by a single request.
+func checkNewInstanceObject(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, uid string, objects *instanceObjects, kind string, name string) bool {
+	var object metav1.Object
+	var err error
+	switch kind {
+	case "Pod":
+		object, err = kubeAPI.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "ReplicaSet":
+		object, err = kubeAPI.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "Job":
+		object, err = kubeAPI.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "PersistentVolumeClaim":
+		object, err = kubeAPI.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	default:
+		return false
+	}
+	if err != nil {
+		return false
+	}
+	if belongsToInstance(object, uid) {
+		return true
+	}
+	controller := metav1.GetControllerOfNoCopy(object)
+	return controller != nil && objects.ownsObject(controller.Kind, controller.Name)
+}
+
+//Format event time, empty if it is not known
+func formatEventTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func describeEvent(event *apiv1.Event) *v1.InstanceEvent {
+	first := event.FirstTimestamp.Time
+	if first.IsZero() {
+		first = event.EventTime.Time
+	}
+	source := event.Source.Component
+	if len(source) == 0 {
+		source = event.ReportingController
+	}
+	return &v1.InstanceEvent{
+		Type: event.Type,
+		Reason: event.Reason,
+		Message: event.Message,
+		Object: event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+		Count: event.Count,
+		FirstTimestamp: formatEventTime(first),
+		LastTimestamp: formatEventTime(eventTime(event)),
+		Source: source,
+	}
+}
+
+//List events of instance objects accepted by filter, oldest first, together with resource version to watch from
+func listInstanceEvents(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, filter *eventFilter, objects *instanceObjects) ([]apiv1.Event, string, error) {
+	events, err := kubeAPI.CoreV1().Events(namespace).List(ctx, filter.listOptions())
+	if err != nil {
+		return nil, "", err
+	}
+	matching := make([]apiv1.Event, 0)
+	for _, event := range events.Items {
+		if filter.matches(&event) && objects.owns(&event) {
+			matching = append(matching, event)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return eventTime(&matching[i]).Before(eventTime(&matching[j]))
+	})
+	return matching, events.ResourceVersion, nil
+}
+
+func (s *eventServiceServer) ListInstanceEvents(ctx context.Context, req *v1.InstanceEventsRequest) (*v1.InstanceEventsResponse, error) {
+	logLine("> Entered ListInstanceEvents method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Instance
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareInstanceEventsResponse(v1.Status_FAILED, namespaceNotFound, nil), err
+	}
+
+	filter, err := newEventFilter(req)
+	if err != nil {
+		return prepareInstanceEventsResponse(v1.Status_FAILED, "Invalid event filter", nil), err
+	}
+
+	objects, err := findInstanceObjects(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return prepareInstanceEventsResponse(v1.Status_FAILED, "Issue with collecting instance objects", nil), err
+	}
+
+	events, _, err := listInstanceEvents(ctx, s.kubeAPI, depl.Namespace, filter, objects)
+	if err != nil {
+		return prepareInstanceEventsResponse(v1.Status_FAILED, "Error while retrieving events!", nil), err
+	}
+
+	described := make([]*v1.InstanceEvent, 0, len(events))
+	for i := range events {
+		described = append(described, describeEvent(&events[i]))
+	}
+
+	logLine(fmt.Sprintf("< Found %d events of instance %s", len(described), depl.Uid))
+	return prepareInstanceEventsResponse(v1.Status_OK, "Events retrieved", described), nil
+}
+
+//Send events of instance objects coming from watch until it ends, keeping track of resource version to continue from
+func (s *eventServiceServer) forwardEvents(ctx context.Context, w watch.Interface, depl *v1.Instance, filter *eventFilter, objects *instanceObjects, sent map[types.UID]string, resourceVersion *string, stream v1.EventService_WatchInstanceEventsServer) error {
+	for {
+		var change watch.Event
+		var open bool
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, open = <-w.ResultChan():
+		}
+		if !open {
+			return nil
+		}
+		if change.Type == watch.Error {
+			//resource version is too old, watch is started again from the current state
+			*resourceVersion = ""
+			return nil
+		}
+		event, ok := change.Object.(*apiv1.Event)
+		if !ok || change.Type == watch.Deleted || !filter.matches(event) {
+			continue
+		}
+		*resourceVersion = event.ResourceVersion
+		if sent[event.UID] == event.ResourceVersion && len(event.ResourceVersion) > 0 {
+			continue
+		}
+		if !objects.owns(event) {
+			//object may have been created after the watch started, e.g. a new pod
+			key := event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name
+			if objects.foreign[key] {
+				continue
+			}
+			if !checkNewInstanceObject(ctx, s.kubeAPI, depl.Namespace, depl.Uid, objects, event.InvolvedObject.Kind, event.InvolvedObject.Name) {
+				objects.foreign[key] = true
+				continue
+			}
+			objects.names[key] = true
+		}
+		sent[event.UID] = event.ResourceVersion
+		if err := stream.Send(prepareInstanceEventsResponse(v1.Status_OK, "", []*v1.InstanceEvent{describeEvent(event)})); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *eventServiceServer) WatchInstanceEvents(req *v1.InstanceEventsRequest, stream v1.EventService_WatchInstanceEventsServer) error {
+	logLine("> Entered WatchInstanceEvents method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	depl := req.Instance
+
+	timeout := defaultEventWatchTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(stream.Context(), timeout)
+	defer cancel()
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareInstanceEventsResponse(v1.Status_FAILED, namespaceNotFound, nil))
+		return err
+	}
+
+	filter, err := newEventFilter(req)
+	if err != nil {
+		_ = stream.Send(prepareInstanceEventsResponse(v1.Status_FAILED, "Invalid event filter", nil))
+		return err
+	}
+
+	objects, err := findInstanceObjects(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		_ = stream.Send(prepareInstanceEventsResponse(v1.Status_FAILED, "Issue with collecting instance objects", nil))
+		return err
+	}
+
+	events, resourceVersion, err := listInstanceEvents(ctx, s.kubeAPI, depl.Namespace, filter, objects)
+	if err != nil {
+		_ = stream.Send(prepareInstanceEventsResponse(v1.Status_FAILED, "Error while retrieving events!", nil))
+		return err
+	}
+
+	//events already sent, so that a restarted watch does not repeat them
+	sent := make(map[types.UID]string)
+	initial := make([]*v1.InstanceEvent, 0, len(events))
+	for i := range events {
+		sent[events[i].UID] = events[i].ResourceVersion
+		initial = append(initial, describeEvent(&events[i]))
+	}
+	if err = stream.Send(prepareInstanceEventsResponse(v1.Status_OK, "Events retrieved", initial)); err != nil {
+		return err
+	}
+
+	for {
+		opts := filter.listOptions()
+		opts.ResourceVersion = resourceVersion
+		w, err := s.kubeAPI.CoreV1().Events(depl.Namespace).Watch(ctx, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logLine(fmt.Sprintf("Could not start events watch: %v", err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(readinessResyncInterval):
+			}
+			resourceVersion = ""
+			continue
+		}
+
+		err = s.forwardEvents(ctx, w, depl, filter, objects, sent, &resourceVersion, stream)
+		w.Stop()
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if stream.Context().Err() != nil {
+		return stream.Context().Err()
+	}
+	logLine(fmt.Sprintf("< Events watch of instance %s ended", depl.Uid))
+	return nil
+}
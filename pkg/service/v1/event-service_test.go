@@ -0,0 +1,222 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+func createMockEvent(client *testclient.Clientset, name string, eventType string, kind string, object string, age time.Duration) {
+	event := corev1.Event{}
+	event.Name = name
+	event.UID = types.UID(name)
+	event.Type = eventType
+	event.Reason = "Testing"
+	event.Message = "event of " + object
+	event.Count = 1
+	event.InvolvedObject = corev1.ObjectReference{Kind: kind, Name: object}
+	event.Source.Component = "kubelet"
+	event.LastTimestamp = metav1.NewTime(time.Now().Add(-age))
+	_, _ = client.CoreV1().Events("test-namespace").Create(context.Background(), &event, metav1.CreateOptions{})
+}
+
+//create mock namespace with instance deployment, pod with volume claim, ingress and objects of another instance
+func createEventTestObjects(client *testclient.Clientset) {
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	depl.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test-uid"}}
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+
+	pod := corev1.Pod{}
+	pod.Name = "test-uid-5d9c-abc12"
+	pod.Labels = map[string]string{"app": "test-uid"}
+	pod.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data-volume"}}}}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &pod, metav1.CreateOptions{})
+
+	ingress := networkingv1.Ingress{}
+	ingress.Name = "test-uid-ingress"
+	ingress.Labels = map[string]string{instanceLabel: "test-uid"}
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").Create(context.Background(), &ingress, metav1.CreateOptions{})
+	//ingress of another instance whose uid starts with the same prefix
+	other := networkingv1.Ingress{}
+	other.Name = "test-uid-def-ingress"
+	other.Labels = map[string]string{instanceLabel: "test-uid-def"}
+	_, _ = client.NetworkingV1().Ingresses("test-namespace").Create(context.Background(), &other, metav1.CreateOptions{})
+
+	createMockEvent(client, "e1", corev1.EventTypeNormal, "Deployment", "test-uid", 5 * time.Minute)
+	createMockEvent(client, "e2", corev1.EventTypeNormal, "ReplicaSet", "test-uid-5d9c", 4 * time.Minute)
+	createMockEvent(client, "e3", corev1.EventTypeWarning, "Pod", "test-uid-5d9c-abc12", 3 * time.Minute)
+	createMockEvent(client, "e4", corev1.EventTypeWarning, "PersistentVolumeClaim", "data-volume", 2 * time.Hour)
+	createMockEvent(client, "e5", corev1.EventTypeNormal, "Ingress", "test-uid-ingress", time.Minute)
+	createMockEvent(client, "e6", corev1.EventTypeWarning, "Pod", "test-uid2-0", time.Minute)
+	createMockEvent(client, "e0", corev1.EventTypeWarning, "Ingress", "test-uid-def-ingress", time.Minute)
+}
+
+func TestEventServiceServer_ListInstanceEvents(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewEventServiceServer(client)
+	eventsReq := v1.InstanceEventsRequest{Api: apiVersion, Instance: &inst}
+
+	//Fail on API version check
+	res, err := server.ListInstanceEvents(context.Background(), &v1.InstanceEventsRequest{Api: "dummy", Instance: &inst})
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	res, err = server.ListInstanceEvents(context.Background(), &eventsReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	createEventTestObjects(client)
+
+	//Fail on invalid filter
+	res, err = server.ListInstanceEvents(context.Background(), &v1.InstanceEventsRequest{Api: apiVersion, Instance: &inst, Type: "Error"})
+	if err == nil || res.Message != "Invalid event filter" {
+		t.Fail()
+	}
+	res, err = server.ListInstanceEvents(context.Background(), &v1.InstanceEventsRequest{Api: apiVersion, Instance: &inst, SinceSeconds: 60, SinceTime: "2024-03-01T10:00:00Z"})
+	if err == nil || res.Message != "Invalid event filter" {
+		t.Fail()
+	}
+
+	//Pass
+	res, err = server.ListInstanceEvents(context.Background(), &eventsReq)
+	if err != nil || res.Status != v1.Status_OK || len(res.Events) != 5 {
+		t.Fatal(err)
+	}
+	if res.Events[0].Object != "PersistentVolumeClaim/data-volume" || res.Events[4].Object != "Ingress/test-uid-ingress" || res.Events[4].Source != "kubelet" {
+		t.Fail()
+	}
+
+	//Pass with type and since filters
+	res, err = server.ListInstanceEvents(context.Background(), &v1.InstanceEventsRequest{Api: apiVersion, Instance: &inst, Type: "Warning", SinceSeconds: 3600})
+	if err != nil || len(res.Events) != 1 || res.Events[0].Object != "Pod/test-uid-5d9c-abc12" {
+		t.Fail()
+	}
+}
+
+func TestCheckNewInstanceObject(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	createEventTestObjects(client)
+	objects, err := findInstanceObjects(context.Background(), client, "test-namespace", "test-uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := true
+	claim := corev1.PersistentVolumeClaim{}
+	claim.Name = "cache"
+	claim.Labels = map[string]string{instanceLabel: "test-uid"}
+	_, _ = client.CoreV1().PersistentVolumeClaims("test-namespace").Create(context.Background(), &claim, metav1.CreateOptions{})
+	foreign := corev1.Pod{}
+	foreign.Name = "other-7f8b-abc12"
+	foreign.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "other-7f8b", Controller: &controller}}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &foreign, metav1.CreateOptions{})
+
+	//Pass on labelled claim
+	if !checkNewInstanceObject(context.Background(), client, "test-namespace", "test-uid", objects, "PersistentVolumeClaim", "cache") {
+		t.Fail()
+	}
+	//Fail on pod of another replicaset, missing pod and kinds which are not checked
+	if checkNewInstanceObject(context.Background(), client, "test-namespace", "test-uid", objects, "Pod", "other-7f8b-abc12") {
+		t.Fail()
+	}
+	if checkNewInstanceObject(context.Background(), client, "test-namespace", "test-uid", objects, "Pod", "missing-0") {
+		t.Fail()
+	}
+	if checkNewInstanceObject(context.Background(), client, "test-namespace", "test-uid", objects, "Ingress", "test-uid-ingress") {
+		t.Fail()
+	}
+}
+
+func TestEventServiceServer_WatchInstanceEvents(t *testing.T) {
+	previousInterval := readinessResyncInterval
+	t.Cleanup(func() { readinessResyncInterval = previousInterval })
+	readinessResyncInterval = 50 * time.Millisecond
+
+	client := testclient.NewSimpleClientset()
+	server := NewEventServiceServer(client)
+
+	//Fail on API version check
+	err := server.WatchInstanceEvents(&v1.InstanceEventsRequest{Api: "dummy", Instance: &inst}, newFakeServerStream[v1.InstanceEventsResponse]())
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	stream := newFakeServerStream[v1.InstanceEventsResponse]()
+	err = server.WatchInstanceEvents(&v1.InstanceEventsRequest{Api: apiVersion, Instance: &inst}, stream)
+	if err == nil || (<-stream.responses).Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	createEventTestObjects(client)
+
+	stream = newFakeServerStream[v1.InstanceEventsResponse]()
+	done := make(chan error)
+	go func() {
+		done <- server.WatchInstanceEvents(&v1.InstanceEventsRequest{Api: apiVersion, Instance: &inst, Type: "Warning", TimeoutSeconds: 2}, stream)
+	}()
+
+	//Initial list of events
+	res := <-stream.responses
+	if res.Status != v1.Status_OK || len(res.Events) != 2 {
+		t.Fail()
+	}
+	time.Sleep(100 * time.Millisecond)
+	client.ClearActions()
+
+	//events of other instances and other types are not sent, foreign pod is looked up only once
+	foreign := corev1.Pod{}
+	foreign.Name = "other-0"
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &foreign, metav1.CreateOptions{})
+	createMockEvent(client, "e7", corev1.EventTypeWarning, "Pod", "other-0", 0)
+	createMockEvent(client, "e10", corev1.EventTypeWarning, "Pod", "other-0", 0)
+	createMockEvent(client, "e8", corev1.EventTypeNormal, "Pod", "test-uid-5d9c-abc12", 0)
+
+	//Pass with event of pod created after watch started, owned by replicaset of instance deployment
+	controller := true
+	pod := corev1.Pod{}
+	pod.Name = "web-7f8b-def34"
+	pod.Labels = map[string]string{"app": "test-uid"}
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "test-uid-7f8b", Controller: &controller}}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &pod, metav1.CreateOptions{})
+	createMockEvent(client, "e9", corev1.EventTypeWarning, "Pod", "web-7f8b-def34", 0)
+
+	res = <-stream.responses
+	if len(res.Events) != 1 || res.Events[0].Object != "Pod/web-7f8b-def34" {
+		t.Fail()
+	}
+	foreignGets, lists := 0, 0
+	for _, action := range client.Actions() {
+		if get, ok := action.(k8stesting.GetAction); ok && action.GetVerb() == "get" && get.GetName() == "other-0" {
+			foreignGets++
+		}
+		if action.GetVerb() == "list" {
+			lists++
+		}
+	}
+	if foreignGets != 1 || lists != 0 {
+		t.Fail()
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.responses) != 0 {
+		t.Fail()
+	}
+}
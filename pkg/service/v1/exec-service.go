@@ -0,0 +1,290 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	execAllowAnyCommand = "*"
+)
+
+//Creates executor running command in pod container through exec subresource
+type podExecutorFactory func(namespace string, pod string, opts *apiv1.PodExecOptions) (remotecommand.Executor, error)
+
+type execServiceServer struct {
+	kubeAPI kubernetes.Interface
+	newExecutor podExecutorFactory
+	allowedCommands []string
+}
+
+func NewExecServiceServer(kubeAPI kubernetes.Interface, config *rest.Config, allowedCommands []string) v1.ExecServiceServer {
+	return &execServiceServer{kubeAPI: kubeAPI, newExecutor: newPodExecutor(kubeAPI, config), allowedCommands: allowedCommands}
+}
+
+//Executor talking WebSocket to the API server, falling back to SPDY when server does not support it yet
+func newPodExecutor(kubeAPI kubernetes.Interface, config *rest.Config) podExecutorFactory {
+	return func(namespace string, pod string, opts *apiv1.PodExecOptions) (remotecommand.Executor, error) {
+		url := kubeAPI.CoreV1().RESTClient().Post().
+			Namespace(namespace).
+			Resource("pods").
+			Name(pod).
+			SubResource("exec").
+			VersionedParams(opts, scheme.ParameterCodec).
+			URL()
+		websocket, err := remotecommand.NewWebSocketExecutor(config, "GET", url.String())
+		if err != nil {
+			return nil, err
+		}
+		spdy, err := remotecommand.NewSPDYExecutor(config, "POST", url)
+		if err != nil {
+			return nil, err
+		}
+		return remotecommand.NewFallbackExecutor(websocket, spdy, httpstream.IsUpgradeFailure)
+	}
+}
+
+//Prepare exec response
+func prepareExecResponse(status v1.Status, message string) *v1.ExecResponse {
+	return &v1.ExecResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+	}
+}
+
+//Check command against allow-list. Commands given by path have to match an entry with the same full path,
+//otherwise any executable could be allowed by placing it under an allowed name.
+func isCommandAllowed(command []string, allowed []string) bool {
+	if len(command) == 0 {
+		return false
+	}
+	for _, entry := range allowed {
+		if entry == execAllowAnyCommand || entry == command[0] {
+			return true
+		}
+	}
+	return false
+}
+
+//Shells whose input is not audited, so they can only run commands given with -c and without terminal
+var execShells = map[string]bool{"sh": true, "ash": true, "bash": true, "dash": true, "ksh": true, "zsh": true}
+
+//Check if command starts interactive shell, i.e. shell reading commands from terminal or stdin instead of -c argument
+func isInteractiveShell(command []string, tty bool) bool {
+	if len(command) == 0 || !execShells[path.Base(command[0])] {
+		return false
+	}
+	if tty {
+		return true
+	}
+	for _, arg := range command[1:] {
+		if arg == "-c" {
+			return false
+		}
+	}
+	return true
+}
+
+//Write audit log entry of exec session
+func auditExec(ctx context.Context, depl *v1.Instance, pod string, container string, event string) {
+	client := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		client = p.Addr.String()
+	}
+	logLine(fmt.Sprintf("AUDIT exec client=%s namespace=%s instance=%s pod=%s container=%s %s", client, depl.Namespace, depl.Uid, pod, container, event))
+}
+
+//Terminal resize events received from client, closed when client stops sending
+type execSizeQueue struct {
+	sizes chan remotecommand.TerminalSize
+}
+
+func (q *execSizeQueue) Next() *remotecommand.TerminalSize {
+	size, ok := <-q.sizes
+	if !ok {
+		return nil
+	}
+	return &size
+}
+
+//Forwards output of remote command to client, gRPC streams do not allow concurrent sends
+type execOutput struct {
+	mutex *sync.Mutex
+	stream v1.ExecService_ExecServer
+	stderr bool
+}
+
+func (o *execOutput) Write(p []byte) (int, error) {
+	res := prepareExecResponse(v1.Status_OK, "")
+	if o.stderr {
+		res.Stderr = append([]byte{}, p...)
+	} else {
+		res.Stdout = append([]byte{}, p...)
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if err := o.stream.Send(res); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//Pass stdin and resize events of client to remote command until client closes its side of the stream
+func forwardExecInput(stream v1.ExecService_ExecServer, stdin *io.PipeWriter, sizes chan remotecommand.TerminalSize) {
+	defer close(sizes)
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			_ = stdin.CloseWithError(err)
+			return
+		}
+		if req.Resize != nil {
+			//only the latest size matters, so a size not yet consumed by remote side is replaced
+			select {
+			case <-sizes:
+			default:
+			}
+			sizes <- remotecommand.TerminalSize{Width: uint16(req.Resize.Width), Height: uint16(req.Resize.Height)}
+		}
+		if len(req.Stdin) > 0 {
+			if _, err = stdin.Write(req.Stdin); err != nil {
+				return
+			}
+		}
+		if req.CloseStdin {
+			_ = stdin.Close()
+		}
+	}
+}
+
+func (s *execServiceServer) Exec(stream v1.ExecService_ExecServer) error {
+	logLine("> Entered Exec method")
+
+	ctx := stream.Context()
+
+	//first message describes command to run
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	depl := req.Instance
+	if depl == nil || len(req.Pod) == 0 {
+		return status.Errorf(codes.InvalidArgument, "instance and pod have to be given in the first exec message")
+	}
+
+	//check if given k8s namespace exists
+	_, err = s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareExecResponse(v1.Status_FAILED, namespaceNotFound))
+		return err
+	}
+
+	//only pods belonging to the instance can be entered
+	pod, container, err := findInstanceContainer(ctx, s.kubeAPI, depl, req.Pod, req.Container)
+	if err != nil {
+		_ = stream.Send(prepareExecResponse(v1.Status_FAILED, "Pod or container not found"))
+		return err
+	}
+
+	if !isCommandAllowed(req.Command, s.allowedCommands) {
+		auditExec(ctx, depl, pod.Name, container, fmt.Sprintf("denied command=%q", req.Command))
+		_ = stream.Send(prepareExecResponse(v1.Status_FAILED, "Command not allowed"))
+		return status.Errorf(codes.PermissionDenied, "command %q is not allowed", strings.Join(req.Command, " "))
+	}
+	if isInteractiveShell(req.Command, req.Tty) {
+		auditExec(ctx, depl, pod.Name, container, fmt.Sprintf("denied interactive shell command=%q tty=%t", req.Command, req.Tty))
+		_ = stream.Send(prepareExecResponse(v1.Status_FAILED, "Interactive shell sessions are not allowed"))
+		return status.Errorf(codes.PermissionDenied, "interactive shell %q is not allowed, commands have to be given with -c", strings.Join(req.Command, " "))
+	}
+
+	opts := &apiv1.PodExecOptions{
+		Container: container,
+		Command: req.Command,
+		Stdin: true,
+		Stdout: true,
+		Stderr: !req.Tty,
+		TTY: req.Tty,
+	}
+	executor, err := s.newExecutor(depl.Namespace, pod.Name, opts)
+	if err != nil {
+		_ = stream.Send(prepareExecResponse(v1.Status_FAILED, "Issue with starting exec session"))
+		return err
+	}
+
+	auditExec(ctx, depl, pod.Name, container, fmt.Sprintf("started command=%q tty=%t", req.Command, req.Tty))
+	start := time.Now()
+
+	stdinReader, stdinWriter := io.Pipe()
+	defer stdinReader.Close()
+	sizes := make(chan remotecommand.TerminalSize, 1)
+	if req.Resize != nil {
+		sizes <- remotecommand.TerminalSize{Width: uint16(req.Resize.Width), Height: uint16(req.Resize.Height)}
+	}
+	go forwardExecInput(stream, stdinWriter, sizes)
+
+	var mutex sync.Mutex
+	streamOpts := remotecommand.StreamOptions{
+		Stdin: stdinReader,
+		Stdout: &execOutput{mutex: &mutex, stream: stream},
+		Tty: req.Tty,
+	}
+	if req.Tty {
+		streamOpts.TerminalSizeQueue = &execSizeQueue{sizes: sizes}
+	} else {
+		streamOpts.Stderr = &execOutput{mutex: &mutex, stream: stream, stderr: true}
+	}
+	err = executor.StreamWithContext(ctx, streamOpts)
+
+	res := prepareExecResponse(v1.Status_OK, "Command finished")
+	res.Exited = true
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = int32(exitErr.ExitStatus())
+		res.Message = fmt.Sprintf("Command exited with code %d", res.ExitCode)
+	} else if err != nil {
+		res.Status, res.Exited, res.Message = v1.Status_FAILED, false, fmt.Sprintf("Exec session failed: %v", err)
+	}
+	auditExec(ctx, depl, pod.Name, container, fmt.Sprintf("finished exitCode=%d duration=%s error=%v", res.ExitCode, time.Since(start).Round(time.Millisecond), err))
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if sendErr := stream.Send(res); sendErr != nil {
+		return sendErr
+	}
+	if !res.Exited {
+		return status.Errorf(codes.Unavailable, "exec session of pod %s failed: %v", pod.Name, err)
+	}
+
+	logLine(fmt.Sprintf("< Exec session in pod %s finished", pod.Name))
+	return nil
+}
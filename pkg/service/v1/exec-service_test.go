@@ -0,0 +1,181 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	"errors"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+	"testing"
+)
+
+type fakeExecStream struct {
+	fakeServerStream[v1.ExecResponse]
+	requests chan *v1.ExecRequest
+}
+
+func (f *fakeExecStream) Recv() (*v1.ExecRequest, error) {
+	select {
+	case req, ok := <-f.requests:
+		if !ok {
+			return nil, io.EOF
+		}
+		return req, nil
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+}
+
+func newFakeExecStream(requests ...*v1.ExecRequest) *fakeExecStream {
+	stream := &fakeExecStream{requests: make(chan *v1.ExecRequest, 10)}
+	stream.ctx = context.Background()
+	for _, req := range requests {
+		stream.requests <- req
+	}
+	close(stream.requests)
+	return stream
+}
+
+//Executor echoing stdin to stdout and exiting with code 3
+type fakeExecutor struct {
+	opts *corev1.PodExecOptions
+}
+
+func (f *fakeExecutor) Stream(options remotecommand.StreamOptions) error {
+	return f.StreamWithContext(context.Background(), options)
+}
+
+func (f *fakeExecutor) StreamWithContext(ctx context.Context, options remotecommand.StreamOptions) error {
+	input, _ := io.ReadAll(options.Stdin)
+	_, _ = options.Stdout.Write(input)
+	if options.Stderr != nil {
+		_, _ = options.Stderr.Write([]byte("warning"))
+	}
+	return utilexec.CodeExitError{Err: errors.New("command terminated with non-zero exit code"), Code: 3}
+}
+
+func TestExecServiceServer_Exec(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	executor := &fakeExecutor{}
+	server := &execServiceServer{kubeAPI: client, allowedCommands: []string{"sh", "/bin/sh"}, newExecutor: func(namespace string, pod string, opts *corev1.PodExecOptions) (remotecommand.Executor, error) {
+		executor.opts = opts
+		return executor, nil
+	}}
+	start := &v1.ExecRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Command: []string{"/bin/sh", "-c", "cat"}}
+
+	//Fail on API version check
+	err := server.Exec(newFakeExecStream(&v1.ExecRequest{Api: "dummy", Instance: &inst, Pod: "test-uid-0"}))
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	stream := newFakeExecStream(start)
+	err = server.Exec(stream)
+	if err == nil || stream.sent[0].Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on pod not belonging to instance
+	p1 := corev1.Pod{}
+	p1.Name = "test-uid-0"
+	p1.Spec.Containers = []corev1.Container{{Name: "app"}}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p1, metav1.CreateOptions{})
+	stream = newFakeExecStream(start)
+	err = server.Exec(stream)
+	if err == nil || stream.sent[0].Message != "Pod or container not found" {
+		t.Fail()
+	}
+
+	p1.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	_, _ = client.CoreV1().Pods("test-namespace").Update(context.Background(), &p1, metav1.UpdateOptions{})
+
+	//Fail on unknown container
+	stream = newFakeExecStream(&v1.ExecRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Container: "sidecar", Command: []string{"sh"}})
+	err = server.Exec(stream)
+	if err == nil || stream.sent[0].Message != "Pod or container not found" {
+		t.Fail()
+	}
+
+	//Fail on command not allowed
+	stream = newFakeExecStream(&v1.ExecRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Command: []string{"rm", "-rf", "/"}})
+	err = server.Exec(stream)
+	if err == nil || stream.sent[0].Message != "Command not allowed" {
+		t.Fail()
+	}
+
+	//Fail on interactive shell, with terminal or reading commands from stdin
+	stream = newFakeExecStream(&v1.ExecRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Command: []string{"sh"}, Tty: true})
+	err = server.Exec(stream)
+	if err == nil || stream.sent[0].Message != "Interactive shell sessions are not allowed" {
+		t.Fail()
+	}
+	stream = newFakeExecStream(&v1.ExecRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Command: []string{"/bin/sh"}})
+	err = server.Exec(stream)
+	if err == nil || stream.sent[0].Message != "Interactive shell sessions are not allowed" {
+		t.Fail()
+	}
+
+	//Pass
+	stream = newFakeExecStream(start, &v1.ExecRequest{Stdin: []byte("ls\n")}, &v1.ExecRequest{CloseStdin: true})
+	err = server.Exec(stream)
+	if err != nil || len(stream.sent) != 3 {
+		t.Fatal(err)
+	}
+	if executor.opts.Container != "app" || executor.opts.TTY || !executor.opts.Stderr {
+		t.Fail()
+	}
+	if string(stream.sent[0].Stdout) != "ls\n" || string(stream.sent[1].Stderr) != "warning" {
+		t.Fail()
+	}
+	last := stream.sent[2]
+	if !last.Exited || last.ExitCode != 3 || last.Status != v1.Status_OK {
+		t.Fail()
+	}
+}
+
+func TestIsCommandAllowed(t *testing.T) {
+	if isCommandAllowed([]string{"sh"}, []string{}) || isCommandAllowed([]string{}, []string{"*"}) {
+		t.Fail()
+	}
+	if !isCommandAllowed([]string{"bash", "-c", "ls"}, []string{"sh", "bash"}) || !isCommandAllowed([]string{"top"}, []string{"*"}) {
+		t.Fail()
+	}
+	if !isCommandAllowed([]string{"/bin/rm"}, []string{"sh", "/bin/rm"}) || isCommandAllowed([]string{"/usr/bin/rm"}, []string{"sh", "/bin/rm"}) {
+		t.Fail()
+	}
+	//path qualified commands have to match full path entries
+	if isCommandAllowed([]string{"/tmp/ls"}, []string{"ls"}) || isCommandAllowed([]string{"/bin/bash"}, []string{"bash"}) {
+		t.Fail()
+	}
+}
+
+func TestIsInteractiveShell(t *testing.T) {
+	if !isInteractiveShell([]string{"bash"}, false) || !isInteractiveShell([]string{"/bin/sh", "-c", "ls"}, true) {
+		t.Fail()
+	}
+	if isInteractiveShell([]string{"sh", "-c", "ls"}, false) || isInteractiveShell([]string{"top"}, true) || isInteractiveShell([]string{}, true) {
+		t.Fail()
+	}
+}
+
+func TestForwardExecInput(t *testing.T) {
+	//Pass keeping only latest terminal size not yet consumed by remote side
+	stream := newFakeExecStream(&v1.ExecRequest{Resize: &v1.TerminalSize{Width: 80, Height: 24}}, &v1.ExecRequest{Resize: &v1.TerminalSize{Width: 120, Height: 40}})
+	_, stdin := io.Pipe()
+	sizes := make(chan remotecommand.TerminalSize, 1)
+	forwardExecInput(stream, stdin, sizes)
+	size, ok := <-sizes
+	if !ok || size.Width != 120 || size.Height != 40 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"sync"
+)
+
+//Server side of a gRPC stream keeping all sent messages, which are also passed to the responses channel if it is set
+type fakeServerStream[T any] struct {
+	grpc.ServerStream
+	ctx context.Context
+	mutex sync.Mutex
+	sent []*T
+	responses chan *T
+}
+
+func (f *fakeServerStream[T]) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeServerStream[T]) Send(res *T) error {
+	f.mutex.Lock()
+	f.sent = append(f.sent, res)
+	f.mutex.Unlock()
+	if f.responses != nil {
+		f.responses <- res
+	}
+	return nil
+}
+
+//Stream whose sent messages can be awaited through the responses channel
+func newFakeServerStream[T any]() *fakeServerStream[T] {
+	return &fakeServerStream[T]{ctx: context.Background(), responses: make(chan *T, 100)}
+}
@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	helmReleaseSecretType = "helm.sh/release.v1"
+	helmReleaseKey = "release"
+	helmStatusFailed = "failed"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+type helmServiceServer struct {
+	kubeAPI kubernetes.Interface
+}
+
+func NewHelmServiceServer(kubeAPI kubernetes.Interface) v1.HelmServiceServer {
+	return &helmServiceServer{kubeAPI: kubeAPI}
+}
+
+//Subset of Helm v3 release record needed to report release state
+type helmRelease struct {
+	Name string `json:"name"`
+	Version int32 `json:"version"`
+	Info struct {
+		Status string `json:"status"`
+		Description string `json:"description"`
+		LastDeployed string `json:"last_deployed"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name string `json:"name"`
+			Version string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+//Prepare helm release response
+func prepareHelmReleaseResponse(status v1.Status, message string) *v1.HelmReleaseResponse {
+	return &v1.HelmReleaseResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+	}
+}
+
+//Decode release the same way Helm stores it: base64 encoded, gzipped JSON
+func decodeHelmRelease(data []byte) (*helmRelease, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(decoded, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		decoded, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	release := &helmRelease{}
+	err = json.Unmarshal(decoded, release)
+	if err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+//Retrieve all revisions of given release stored in namespace, most recent first
+func findHelmReleases(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, name string) ([]*helmRelease, error) {
+	secrets, err := kubeAPI.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("owner=helm,name=%s", name)})
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*helmRelease, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		if secret.Type != helmReleaseSecretType {
+			continue
+		}
+		release, err := decodeHelmRelease(secret.Data[helmReleaseKey])
+		if err != nil {
+			logLine(fmt.Sprintf("Could not decode helm release secret %s: %v", secret.Name, err))
+			continue
+		}
+		releases = append(releases, release)
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Version > releases[j].Version
+	})
+	return releases, nil
+}
+
+func (s *helmServiceServer) RetrieveReleaseStatus(ctx context.Context, req *v1.InstanceRequest) (*v1.HelmReleaseResponse, error) {
+	logLine("> Entered RetrieveReleaseStatus method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareHelmReleaseResponse(v1.Status_FAILED, namespaceNotFound), err
+	}
+
+	releases, err := findHelmReleases(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return prepareHelmReleaseResponse(v1.Status_FAILED, "Error while retrieving helm release!"), err
+	}
+	if len(releases) == 0 {
+		return prepareHelmReleaseResponse(v1.Status_FAILED, "Helm release not found!"), status.Errorf(codes.NotFound, "helm release %s not found in namespace %s", depl.Uid, depl.Namespace)
+	}
+
+	res := prepareHelmReleaseResponse(v1.Status_OK, "")
+	latest := releases[0]
+	res.ReleaseName = latest.Name
+	res.ReleaseStatus = latest.Info.Status
+	res.ChartName = latest.Chart.Metadata.Name
+	res.ChartVersion = latest.Chart.Metadata.Version
+	res.AppVersion = latest.Chart.Metadata.AppVersion
+	res.Revision = latest.Version
+
+	for _, release := range releases {
+		if len(res.LastFailure) == 0 && release.Info.Status == helmStatusFailed {
+			res.LastFailure = release.Info.Description
+		}
+		res.History = append(res.History, &v1.HelmRevision{
+			Revision: release.Version,
+			Status: release.Info.Status,
+			ChartName: release.Chart.Metadata.Name,
+			ChartVersion: release.Chart.Metadata.Version,
+			AppVersion: release.Chart.Metadata.AppVersion,
+			Description: release.Info.Description,
+			Updated: release.Info.LastDeployed,
+		})
+	}
+
+	logLine(fmt.Sprintf("< Release %s revision %d is %s", res.ReleaseName, res.Revision, res.ReleaseStatus))
+	return res, nil
+}
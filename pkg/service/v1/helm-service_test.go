@@ -0,0 +1,85 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+//Store helm release record the same way Helm v3 does
+func createTestHelmRelease(t *testing.T, client *testclient.Clientset, name string, revision int, releaseStatus string, chartVersion string, description string) {
+	record := fmt.Sprintf(`{"name":"%s","version":%d,"info":{"status":"%s","description":"%s","last_deployed":"2024-03-0%dT10:00:00Z"},"chart":{"metadata":{"name":"grafana","version":"%s","appVersion":"10.4.0"}}}`,
+		name, revision, releaseStatus, description, revision, chartVersion)
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, _ = writer.Write([]byte(record))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sec := corev1.Secret{}
+	sec.Name = fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision)
+	sec.Type = helmReleaseSecretType
+	sec.Labels = map[string]string{"owner": "helm", "name": name, "status": releaseStatus}
+	sec.Data = map[string][]byte{helmReleaseKey: []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))}
+	_, _ = client.CoreV1().Secrets("test-namespace").Create(context.Background(), &sec, metav1.CreateOptions{})
+}
+
+func TestHelmServiceServer_RetrieveReleaseStatus(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewHelmServiceServer(client)
+
+	//Fail on API version check
+	res, err := server.RetrieveReleaseStatus(context.Background(), &illegal_req)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	freq := v1.InstanceRequest{Api: apiVersion, Deployment: &fake_ns_inst}
+	res, err = server.RetrieveReleaseStatus(context.Background(), &freq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing release
+	res, err = server.RetrieveReleaseStatus(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	createTestHelmRelease(t, client, "test-uid", 1, "superseded", "7.3.0", "Install complete")
+	createTestHelmRelease(t, client, "test-uid", 2, "failed", "7.3.7", "Upgrade \\\"test-uid\\\" failed: timed out waiting for the condition")
+	createTestHelmRelease(t, client, "test-uid", 3, "deployed", "7.3.0", "Rollback to 1")
+	createTestHelmRelease(t, client, "other-uid", 4, "deployed", "8.0.0", "Install complete")
+
+	//Pass
+	res, err = server.RetrieveReleaseStatus(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+	if res.ReleaseName != "test-uid" || res.ReleaseStatus != "deployed" || res.Revision != 3 {
+		t.Fail()
+	}
+	if res.ChartName != "grafana" || res.ChartVersion != "7.3.0" || res.AppVersion != "10.4.0" {
+		t.Fail()
+	}
+	if res.LastFailure != "Upgrade \"test-uid\" failed: timed out waiting for the condition" {
+		t.Fail()
+	}
+	if len(res.History) != 3 || res.History[0].Revision != 3 || res.History[1].ChartVersion != "7.3.7" || res.History[2].Updated != "2024-03-01T10:00:00Z" {
+		t.Fail()
+	}
+}
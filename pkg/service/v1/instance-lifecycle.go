@@ -0,0 +1,215 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	suspendedReplicasAnnotation = "janitor.nmaas.eu/suspended-replicas"
+)
+
+type instanceLifecycleServiceServer struct {
+	kubeAPI kubernetes.Interface
+}
+
+func NewInstanceLifecycleServiceServer(kubeAPI kubernetes.Interface) v1.InstanceLifecycleServiceServer {
+	return &instanceLifecycleServiceServer{kubeAPI: kubeAPI}
+}
+
+//Computes new replica count of workload from its current one, annotations can be changed in place
+type replicasUpdate func(annotations map[string]string, replicas int32) (int32, error)
+
+//Prepare instance lifecycle response
+func prepareInstanceLifecycleResponse(status v1.Status, message string, workloads []*v1.ScaledWorkload) *v1.InstanceLifecycleResponse {
+	return &v1.InstanceLifecycleResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Workloads: workloads,
+	}
+}
+
+//Scale to zero, remembering original replicas unless workload has already been suspended
+func suspendReplicas(annotations map[string]string, replicas int32) (int32, error) {
+	if _, suspended := annotations[suspendedReplicasAnnotation]; !suspended {
+		annotations[suspendedReplicasAnnotation] = strconv.Itoa(int(replicas))
+	}
+	return 0, nil
+}
+
+//Restore replicas remembered on suspend, workloads which were not suspended are left unchanged
+func resumeReplicas(annotations map[string]string, replicas int32) (int32, error) {
+	value, suspended := annotations[suspendedReplicasAnnotation]
+	if !suspended {
+		return replicas, nil
+	}
+	original, err := strconv.Atoi(value)
+	if err != nil || original < 0 {
+		return replicas, status.Errorf(codes.FailedPrecondition, "invalid suspended replicas annotation %s", value)
+	}
+	delete(annotations, suspendedReplicasAnnotation)
+	return int32(original), nil
+}
+
+//Set explicit replicas, scaling up ends suspension
+func explicitReplicas(target int32) replicasUpdate {
+	return func(annotations map[string]string, replicas int32) (int32, error) {
+		if target > 0 {
+			delete(annotations, suspendedReplicasAnnotation)
+		}
+		return target, nil
+	}
+}
+
+//Update replicas and annotations of deployment or statefulset, retrying when workload was changed in the meantime
+func updateWorkloadReplicas(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, kind string, name string, update replicasUpdate) (*v1.ScaledWorkload, error) {
+	scaled := &v1.ScaledWorkload{Kind: kind, Name: name}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		switch kind {
+		case "Deployment":
+			dep, err := kubeAPI.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if dep.Annotations == nil {
+				dep.Annotations = make(map[string]string)
+			}
+			scaled.PreviousReplicas = replicasOrDefault(dep.Spec.Replicas)
+			if scaled.Replicas, err = update(dep.Annotations, scaled.PreviousReplicas); err != nil {
+				return err
+			}
+			dep.Spec.Replicas = &scaled.Replicas
+			_, err = kubeAPI.AppsV1().Deployments(namespace).Update(ctx, dep, metav1.UpdateOptions{})
+			return err
+		case "StatefulSet":
+			sts, err := kubeAPI.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if sts.Annotations == nil {
+				sts.Annotations = make(map[string]string)
+			}
+			scaled.PreviousReplicas = replicasOrDefault(sts.Spec.Replicas)
+			if scaled.Replicas, err = update(sts.Annotations, scaled.PreviousReplicas); err != nil {
+				return err
+			}
+			sts.Spec.Replicas = &scaled.Replicas
+			_, err = kubeAPI.AppsV1().StatefulSets(namespace).Update(ctx, sts, metav1.UpdateOptions{})
+			return err
+		}
+		return status.Errorf(codes.InvalidArgument, "%s %s cannot be scaled", kind, name)
+	})
+	return scaled, err
+}
+
+//Apply replicas update to instance deployments and statefulsets, or only to the one with given name
+func (s *instanceLifecycleServiceServer) scaleInstance(ctx context.Context, depl *v1.Instance, workload string, update replicasUpdate) ([]*v1.ScaledWorkload, error) {
+	workloads, err := findInstanceWorkloads(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return nil, err
+	}
+
+	scaled := make([]*v1.ScaledWorkload, 0)
+	for _, w := range workloads {
+		kind, name := w.status.Kind, w.status.Name
+		if (kind != "Deployment" && kind != "StatefulSet") || (len(workload) > 0 && name != workload) {
+			continue
+		}
+		logLine(fmt.Sprintf("Scaling %s %s in namespace %s", kind, name, depl.Namespace))
+		res, err := updateWorkloadReplicas(ctx, s.kubeAPI, depl.Namespace, kind, name, update)
+		if err != nil {
+			return scaled, err
+		}
+		scaled = append(scaled, res)
+	}
+	if len(scaled) == 0 {
+		return scaled, status.Errorf(codes.NotFound, "no deployments or statefulsets to scale found for instance %s", depl.Uid)
+	}
+	return scaled, nil
+}
+
+func (s *instanceLifecycleServiceServer) Suspend(ctx context.Context, req *v1.InstanceRequest) (*v1.InstanceLifecycleResponse, error) {
+	logLine("> Entered Suspend method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareInstanceLifecycleResponse(v1.Status_FAILED, namespaceNotFound, nil), err
+	}
+
+	scaled, err := s.scaleInstance(ctx, depl, "", suspendReplicas)
+	if err != nil {
+		return prepareInstanceLifecycleResponse(v1.Status_FAILED, "Issue with suspending instance", scaled), err
+	}
+
+	logLine(fmt.Sprintf("< Suspended %d workloads of instance %s", len(scaled), depl.Uid))
+	return prepareInstanceLifecycleResponse(v1.Status_OK, "Instance suspended", scaled), nil
+}
+
+func (s *instanceLifecycleServiceServer) Resume(ctx context.Context, req *v1.InstanceRequest) (*v1.InstanceLifecycleResponse, error) {
+	logLine("> Entered Resume method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareInstanceLifecycleResponse(v1.Status_FAILED, namespaceNotFound, nil), err
+	}
+
+	scaled, err := s.scaleInstance(ctx, depl, "", resumeReplicas)
+	if err != nil {
+		return prepareInstanceLifecycleResponse(v1.Status_FAILED, "Issue with resuming instance", scaled), err
+	}
+
+	logLine(fmt.Sprintf("< Resumed %d workloads of instance %s", len(scaled), depl.Uid))
+	return prepareInstanceLifecycleResponse(v1.Status_OK, "Instance resumed", scaled), nil
+}
+
+func (s *instanceLifecycleServiceServer) Scale(ctx context.Context, req *v1.InstanceScaleRequest) (*v1.InstanceLifecycleResponse, error) {
+	logLine("> Entered Scale method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Instance
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareInstanceLifecycleResponse(v1.Status_FAILED, namespaceNotFound, nil), err
+	}
+
+	if req.Replicas < 0 {
+		return prepareInstanceLifecycleResponse(v1.Status_FAILED, "Invalid number of replicas", nil), status.Errorf(codes.InvalidArgument, "number of replicas must not be negative")
+	}
+
+	scaled, err := s.scaleInstance(ctx, depl, req.Workload, explicitReplicas(req.Replicas))
+	if err != nil {
+		return prepareInstanceLifecycleResponse(v1.Status_FAILED, "Issue with scaling instance", scaled), err
+	}
+
+	logLine(fmt.Sprintf("< Scaled %d workloads of instance %s to %d replicas", len(scaled), depl.Uid, req.Replicas))
+	return prepareInstanceLifecycleResponse(v1.Status_OK, "Instance scaled", scaled), nil
+}
@@ -0,0 +1,138 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestInstanceLifecycleServiceServer_SuspendResume(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewInstanceLifecycleServiceServer(client)
+
+	//Fail on API version check
+	res, err := server.Suspend(context.Background(), &illegal_req)
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	res, err = server.Suspend(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on no workloads
+	res, err = server.Suspend(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock deployment with default replicas and labelled statefulset with 3 replicas
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+	sts := appsv1.StatefulSet{}
+	sts.Name = "test-uid-db"
+	sts.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	q := int32(3)
+	sts.Spec.Replicas = &q
+	_, _ = client.AppsV1().StatefulSets("test-namespace").Create(context.Background(), &sts, metav1.CreateOptions{})
+
+	//Pass
+	res, err = server.Suspend(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK || len(res.Workloads) != 2 {
+		t.Fatal(err)
+	}
+	d, _ := client.AppsV1().Deployments("test-namespace").Get(context.Background(), "test-uid", metav1.GetOptions{})
+	s, _ := client.AppsV1().StatefulSets("test-namespace").Get(context.Background(), "test-uid-db", metav1.GetOptions{})
+	if *d.Spec.Replicas != 0 || d.Annotations["janitor.nmaas.eu/suspended-replicas"] != "1" {
+		t.Fail()
+	}
+	if *s.Spec.Replicas != 0 || s.Annotations["janitor.nmaas.eu/suspended-replicas"] != "3" {
+		t.Fail()
+	}
+
+	//Pass on suspending again, original replicas are kept
+	res, err = server.Suspend(context.Background(), &req)
+	s, _ = client.AppsV1().StatefulSets("test-namespace").Get(context.Background(), "test-uid-db", metav1.GetOptions{})
+	if err != nil || s.Annotations["janitor.nmaas.eu/suspended-replicas"] != "3" {
+		t.Fail()
+	}
+
+	//Pass on resume
+	res, err = server.Resume(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+	d, _ = client.AppsV1().Deployments("test-namespace").Get(context.Background(), "test-uid", metav1.GetOptions{})
+	s, _ = client.AppsV1().StatefulSets("test-namespace").Get(context.Background(), "test-uid-db", metav1.GetOptions{})
+	if *d.Spec.Replicas != 1 || *s.Spec.Replicas != 3 {
+		t.Fail()
+	}
+	if _, ok := s.Annotations["janitor.nmaas.eu/suspended-replicas"]; ok {
+		t.Fail()
+	}
+
+	//Pass on resuming instance that is not suspended
+	res, err = server.Resume(context.Background(), &req)
+	if err != nil || res.Workloads[1].Replicas != 3 {
+		t.Fail()
+	}
+}
+
+func TestInstanceLifecycleServiceServer_Scale(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewInstanceLifecycleServiceServer(client)
+
+	//Fail on API version check
+	res, err := server.Scale(context.Background(), &v1.InstanceScaleRequest{Api: "illegal", Instance: &inst, Replicas: 2})
+	if err == nil {
+		t.Fail()
+	}
+
+	//create mock namespace and suspended deployment
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	depl.Annotations = map[string]string{"janitor.nmaas.eu/suspended-replicas": "1"}
+	zero := int32(0)
+	depl.Spec.Replicas = &zero
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+
+	//Fail on negative replicas
+	res, err = server.Scale(context.Background(), &v1.InstanceScaleRequest{Api: apiVersion, Instance: &inst, Replicas: -1})
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Fail on unknown workload
+	res, err = server.Scale(context.Background(), &v1.InstanceScaleRequest{Api: apiVersion, Instance: &inst, Replicas: 2, Workload: "test-uid-web"})
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Pass
+	res, err = server.Scale(context.Background(), &v1.InstanceScaleRequest{Api: apiVersion, Instance: &inst, Replicas: 2, Workload: "test-uid"})
+	if err != nil || res.Status != v1.Status_OK || res.Workloads[0].PreviousReplicas != 0 || res.Workloads[0].Replicas != 2 {
+		t.Fatal(err)
+	}
+	d, _ := client.AppsV1().Deployments("test-namespace").Get(context.Background(), "test-uid", metav1.GetOptions{})
+	if *d.Spec.Replicas != 2 {
+		t.Fail()
+	}
+	if _, ok := d.Annotations["janitor.nmaas.eu/suspended-replicas"]; ok {
+		t.Fail()
+	}
+}
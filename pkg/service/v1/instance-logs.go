@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+//Maximum number of containers whose logs are fetched at the same time
+var instanceLogsConcurrency = 4
+
+//Maximum number of log bytes collected from all containers of instance together
+var instanceLogsByteBudget = int64(64 * 1024 * 1024)
+
+//Log bytes which can still be collected, shared by all containers of instance
+type logByteBudget struct {
+	limit int64
+	remaining atomic.Int64
+}
+
+func newLogByteBudget(limit int64) *logByteBudget {
+	budget := &logByteBudget{limit: limit}
+	budget.remaining.Store(limit)
+	return budget
+}
+
+//Account given number of bytes, returns false once budget is exhausted
+func (b *logByteBudget) take(n int) bool {
+	return b.remaining.Add(-int64(n)) >= 0
+}
+
+func (b *logByteBudget) exhausted() bool {
+	return b.remaining.Load() < 0
+}
+
+//Fetch logs of a single container, failure is reported as a log line so that other containers are still returned
+func (s *podServiceServer) fetchContainerLogs(ctx context.Context, namespace string, pod string, container string, opts apiv1.PodLogOptions, filterSpec *v1.LogFilter, budget *logByteBudget) []*v1.PodLogLine {
+	opts.Container = container
+	lines := make([]*v1.PodLogLine, 0)
+	exhaustedMessage := fmt.Sprintf("Instance log byte budget of %d bytes exhausted", budget.limit)
+
+	if budget.exhausted() {
+		return append(lines, preparePodLogLine(v1.Status_FAILED, exhaustedMessage, pod, container, "", ""))
+	}
+
+	//filter keeps context state, so every container needs its own
+	filter, err := newLogFilter(filterSpec)
+	if err != nil {
+		return append(lines, preparePodLogLine(v1.Status_FAILED, "Invalid log filter", pod, container, "", ""))
+	}
+
+	podLogs, err := s.kubeAPI.CoreV1().Pods(namespace).GetLogs(pod, &opts).Stream(ctx)
+	if err != nil {
+		return append(lines, preparePodLogLine(v1.Status_FAILED, fmt.Sprintf("Issue with opening stream with logs: %v", err), pod, container, "", ""))
+	}
+	defer podLogs.Close()
+
+	err = forwardLogLines(podLogs, pod, container, podLogStreamByteBudget, filter, func(line *v1.PodLogLine) error {
+		if line.Status == v1.Status_OK && !budget.take(len(line.Line)) {
+			return status.Errorf(codes.ResourceExhausted, "logs of instance exceeded %d bytes", budget.limit)
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	if status.Code(err) == codes.ResourceExhausted && budget.exhausted() {
+		lines = append(lines, preparePodLogLine(v1.Status_FAILED, exhaustedMessage, pod, container, "", ""))
+	} else if err != nil {
+		lines = append(lines, preparePodLogLine(v1.Status_FAILED, fmt.Sprintf("Issue with reading logs: %v", err), pod, container, "", ""))
+	}
+	return lines
+}
+
+//Merge log lines of several containers into a single time ordered list, lines without timestamp go first
+func mergeLogLines(groups [][]*v1.PodLogLine) []*v1.PodLogLine {
+	type timedLine struct {
+		time time.Time
+		line *v1.PodLogLine
+	}
+
+	timed := make([]timedLine, 0)
+	for _, group := range groups {
+		for _, line := range group {
+			t, _ := time.Parse(time.RFC3339Nano, line.Timestamp)
+			timed = append(timed, timedLine{time: t, line: line})
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].time.Before(timed[j].time)
+	})
+
+	merged := make([]*v1.PodLogLine, 0, len(timed))
+	for _, t := range timed {
+		merged = append(merged, t.line)
+	}
+	return merged
+}
+
+func (s *podServiceServer) RetrieveInstanceLogs(req *v1.InstanceLogsRequest, stream v1.PodService_RetrieveInstanceLogsServer) error {
+	logLine("> Entered RetrieveInstanceLogs method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	depl := req.Instance
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, namespaceNotFound, "", "", "", ""))
+		return err
+	}
+
+	opts, err := preparePodLogOptions(&v1.PodRequest{
+		Pod: &v1.PodInfo{},
+		TailLines: req.TailLines,
+		SinceSeconds: req.SinceSeconds,
+		SinceTime: req.SinceTime,
+		LimitBytes: req.LimitBytes,
+		Previous: req.Previous,
+	})
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, "Invalid log options", "", "", "", ""))
+		return err
+	}
+	//timestamps are needed to order lines of different containers
+	opts.Timestamps = true
+
+	if _, err = newLogFilter(req.Filter); err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, "Invalid log filter", "", "", "", ""))
+		return err
+	}
+
+	pods, err := findInstancePods(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, "Issue with collecting pods", "", "", "", ""))
+		return err
+	}
+
+	budget := newLogByteBudget(instanceLogsByteBudget)
+	groups := make([][]*v1.PodLogLine, 0)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, instanceLogsConcurrency)
+	for _, pod := range pods {
+		containers := append(append([]apiv1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for _, container := range containers {
+			wg.Add(1)
+			go func(pod string, container string) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				lines := s.fetchContainerLogs(ctx, depl.Namespace, pod, container, *opts, req.Filter, budget)
+				mutex.Lock()
+				groups = append(groups, lines)
+				mutex.Unlock()
+			}(pod.Name, container.Name)
+		}
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	merged := mergeLogLines(groups)
+	for _, line := range merged {
+		if err = stream.Send(line); err != nil {
+			return err
+		}
+	}
+
+	logLine(fmt.Sprintf("< Sent %d lines from %d pods", len(merged), len(pods)))
+	return nil
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestPodServiceServer_RetrieveInstanceLogs(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewPodServiceServer(client, nil)
+
+	//Fail on API version check
+	err := server.RetrieveInstanceLogs(&v1.InstanceLogsRequest{Api: "dummy", Instance: &inst}, &fakeServerStream[v1.PodLogLine]{ctx: context.Background()})
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	logsReq := v1.InstanceLogsRequest{Api: apiVersion, Instance: &inst, TailLines: 50}
+	stream := &fakeServerStream[v1.PodLogLine]{ctx: context.Background()}
+	err = server.RetrieveInstanceLogs(&logsReq, stream)
+	if err == nil || stream.sent[0].Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on invalid log options
+	stream = &fakeServerStream[v1.PodLogLine]{ctx: context.Background()}
+	err = server.RetrieveInstanceLogs(&v1.InstanceLogsRequest{Api: apiVersion, Instance: &inst, SinceTime: "yesterday"}, stream)
+	if err == nil || stream.sent[0].Message != "Invalid log options" {
+		t.Fail()
+	}
+
+	//create mock pods, one with init container and one labelled with the instance
+	p1 := corev1.Pod{}
+	p1.Name = "test-uid-web-0"
+	p1.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	p1.Spec.InitContainers = []corev1.Container{{Name: "migrate"}}
+	p1.Spec.Containers = []corev1.Container{{Name: "app"}, {Name: "proxy"}}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p1, metav1.CreateOptions{})
+
+	p2 := corev1.Pod{}
+	p2.Name = "database-0"
+	p2.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	p2.Spec.Containers = []corev1.Container{{Name: "postgres"}}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p2, metav1.CreateOptions{})
+
+	p3 := corev1.Pod{}
+	p3.Name = "other-uid-0"
+	p3.Spec.Containers = []corev1.Container{{Name: "app"}}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p3, metav1.CreateOptions{})
+
+	//Pass
+	stream = &fakeServerStream[v1.PodLogLine]{ctx: context.Background()}
+	err = server.RetrieveInstanceLogs(&logsReq, stream)
+	if err != nil || len(stream.sent) != 4 {
+		t.Fatal(err)
+	}
+	containers := make(map[string]bool)
+	for _, line := range stream.sent {
+		containers[line.Pod + "/" + line.Container] = line.Line == "fake logs"
+	}
+	if !containers["test-uid-web-0/migrate"] || !containers["test-uid-web-0/proxy"] || !containers["database-0/postgres"] {
+		t.Fail()
+	}
+
+	//Stop collecting logs once budget shared by all containers is exhausted
+	previousBudget := instanceLogsByteBudget
+	t.Cleanup(func() { instanceLogsByteBudget = previousBudget })
+	instanceLogsByteBudget = int64(2 * len("fake logs"))
+	stream = &fakeServerStream[v1.PodLogLine]{ctx: context.Background()}
+	err = server.RetrieveInstanceLogs(&logsReq, stream)
+	if err != nil || len(stream.sent) != 4 {
+		t.Fatal(err)
+	}
+	collected, exhausted := 0, 0
+	for _, line := range stream.sent {
+		if line.Status == v1.Status_OK {
+			collected++
+		} else if line.Message == "Instance log byte budget of 18 bytes exhausted" {
+			exhausted++
+		}
+	}
+	if collected != 2 || exhausted != 2 {
+		t.Fail()
+	}
+}
+
+func TestMergeLogLines(t *testing.T) {
+	app := []*v1.PodLogLine{
+		preparePodLogLine(v1.Status_OK, "", "web", "app", "2024-03-01T10:00:00.5Z", "app started"),
+		preparePodLogLine(v1.Status_OK, "", "web", "app", "2024-03-01T10:00:02Z", "request served"),
+	}
+	db := []*v1.PodLogLine{
+		preparePodLogLine(v1.Status_OK, "", "db", "postgres", "2024-03-01T10:00:00Z", "database ready"),
+		preparePodLogLine(v1.Status_OK, "", "db", "postgres", "2024-03-01T10:00:01Z", "connection accepted"),
+	}
+	failed := []*v1.PodLogLine{
+		preparePodLogLine(v1.Status_FAILED, "container is waiting to start", "web", "proxy", "", ""),
+	}
+
+	merged := mergeLogLines([][]*v1.PodLogLine{app, db, failed})
+	if len(merged) != 5 || merged[0].Container != "proxy" {
+		t.Fatal(merged)
+	}
+	if merged[1].Line != "database ready" || merged[2].Line != "app started" || merged[3].Line != "connection accepted" || merged[4].Line != "request served" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+//Issue instance certificate from internal CA instead of requesting it from cert-manager
+func (s *certManagerServiceServer) issueFromInternalCa(ctx context.Context, depl *v1.Instance, hostnames []string) (*v1.ServiceResponse, error) {
+	name := getTlsSecretName(depl.Uid)
+
+	//cert-manager would otherwise overwrite the issued certificate
+	s.removeCertManagerCertificate(ctx, depl.Namespace, name, "internal CA")
+
+	logLine(fmt.Sprintf("Issuing certificate %s from internal CA", name))
+	cert, err := s.ca.issueIntoSecret(ctx, depl.Namespace, depl.Uid, hostnames)
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Error while issuing certificate from internal CA!"), err
+	}
+	return prepareResponse(v1.Status_OK, fmt.Sprintf("Certificate %x issued by internal CA", cert.SerialNumber)), nil
+}
+
+//Re-issue internal CA certificate for the same hostnames
+func (s *certManagerServiceServer) renewFromInternalCa(ctx context.Context, depl *v1.Instance, secret *apiv1.Secret, stream v1.CertManagerService_RenewServer) error {
+	previous, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+	if err != nil {
+		_ = stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, "Could not parse certificate", "", ""))
+		return err
+	}
+	previousSerial := fmt.Sprintf("%x", previous.SerialNumber)
+
+	logLine(fmt.Sprintf("Re-issuing certificate %s from internal CA", secret.Name))
+	cert, err := s.ca.issueIntoSecret(ctx, depl.Namespace, depl.Uid, previous.DNSNames)
+	if err != nil {
+		_ = stream.Send(prepareCertificateRenewalResponse(v1.Status_FAILED, "Error while issuing certificate from internal CA!", "", previousSerial))
+		return err
+	}
+	return stream.Send(prepareCertificateRenewalResponse(v1.Status_OK, "Certificate re-issued successfully", fmt.Sprintf("%x", cert.SerialNumber), previousSerial))
+}
+
+func (s *certManagerServiceServer) Revoke(ctx context.Context, req *v1.InstanceRequest) (*v1.ServiceResponse, error) {
+	logLine("> Entered Revoke method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, namespaceNotFound), err
+	}
+
+	secret, err := s.kubeAPI.CoreV1().Secrets(depl.Namespace).Get(ctx, getTlsSecretName(depl.Uid), metav1.GetOptions{})
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Secret not found!"), err
+	}
+	if !isInternalCaCertificate(secret) {
+		return prepareResponse(v1.Status_FAILED, "Certificate was not issued by internal CA"), status.Errorf(codes.FailedPrecondition, "certificate in secret %s was not issued by internal CA", secret.Name)
+	}
+
+	cert, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Could not parse certificate"), err
+	}
+
+	err = s.ca.revoke(ctx, cert)
+	if err != nil {
+		return prepareResponse(v1.Status_FAILED, "Error while revoking certificate!"), err
+	}
+
+	logLine(fmt.Sprintf("< Revoked certificate %x", cert.SerialNumber))
+	return prepareResponse(v1.Status_OK, fmt.Sprintf("Certificate %x revoked successfully", cert.SerialNumber)), nil
+}
+
+func (s *certManagerServiceServer) RetrieveCaCertificate(ctx context.Context, req *v1.InternalCaRequest) (*v1.InfoServiceResponse, error) {
+	logLine("> Entered RetrieveCaCertificate method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	caPem, err := s.ca.certificate(ctx)
+	if err != nil {
+		return prepareInfoResponse(v1.Status_FAILED, "Error while loading internal CA!", ""), err
+	}
+	return prepareInfoResponse(v1.Status_OK, "", string(caPem)), nil
+}
+
+func (s *certManagerServiceServer) RetrieveRevocationList(ctx context.Context, req *v1.InternalCaRequest) (*v1.InfoServiceResponse, error) {
+	logLine("> Entered RetrieveRevocationList method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	crl, err := s.ca.revocationList(ctx)
+	if err != nil {
+		return prepareInfoResponse(v1.Status_FAILED, "Error while loading revocation list!", ""), err
+	}
+	return prepareInfoResponse(v1.Status_OK, "", string(crl)), nil
+}
@@ -0,0 +1,366 @@
+package v1
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	internalCaIssuerKind = "JanitorCA"
+	internalCaSecretName = "nmaas-janitor-ca"
+	internalCaCommonName = "NMaaS Janitor Internal CA"
+	internalCaValidity = 10 * 365 * 24 * time.Hour
+	internalCertificateValidity = 90 * 24 * time.Hour
+	internalCertificateRenewBefore = 30 * 24 * time.Hour
+	revocationListValidity = 7 * 24 * time.Hour
+	revocationListSecretKey = "ca.crl"
+	caCertificateSecretKey = "ca.crt"
+	certificateSourceInternalCa = "internal-ca"
+	certificateIssuerLabel = "janitor.nmaas.eu/issuer"
+)
+
+//internalCA issues instance certificates for deployments without access to ACME.
+//The root certificate, its key and the revocation list are kept in a single Kubernetes Secret.
+type internalCA struct {
+	kubeAPI kubernetes.Interface
+	namespace string
+	mutex sync.Mutex
+}
+
+func newInternalCA(kubeAPI kubernetes.Interface, namespace string) *internalCA {
+	return &internalCA{kubeAPI: kubeAPI, namespace: namespace}
+}
+
+func encodeCertificatePem(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodePrivateKeyPem(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parsePrivateKeyPem(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no private key found in PEM data")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type")
+	}
+	return signer, nil
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+//Generate self-signed root certificate together with an empty revocation list
+func (ca *internalCA) generate(now time.Time) (*apiv1.Secret, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{CommonName: internalCaCommonName},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter: now.Add(internalCaValidity),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		MaxPathLenZero: true,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	root, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := encodePrivateKeyPem(key)
+	if err != nil {
+		return nil, err
+	}
+	crl, err := createRevocationList(root, key, nil, big.NewInt(1), now)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &apiv1.Secret{}
+	secret.SetNamespace(ca.namespace)
+	secret.SetName(internalCaSecretName)
+	secret.Type = apiv1.SecretTypeTLS
+	secret.Data = map[string][]byte{
+		apiv1.TLSCertKey: encodeCertificatePem(der),
+		apiv1.TLSPrivateKeyKey: keyPem,
+		revocationListSecretKey: crl,
+	}
+	return secret, nil
+}
+
+//Load root certificate and key, generating them on first use
+func (ca *internalCA) load(ctx context.Context) (*apiv1.Secret, *x509.Certificate, crypto.Signer, error) {
+	secret, err := ca.kubeAPI.CoreV1().Secrets(ca.namespace).Get(ctx, internalCaSecretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		logLine(fmt.Sprintf("Generating internal CA in secret %s in namespace %s", internalCaSecretName, ca.namespace))
+		secret, err = ca.generate(time.Now())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		secret, err = ca.kubeAPI.CoreV1().Secrets(ca.namespace).Create(ctx, secret, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			secret, err = ca.kubeAPI.CoreV1().Secrets(ca.namespace).Get(ctx, internalCaSecretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	root, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := parsePrivateKeyPem(secret.Data[apiv1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return secret, root, key, nil
+}
+
+func createRevocationList(root *x509.Certificate, key crypto.Signer, entries []x509.RevocationListEntry, number *big.Int, now time.Time) ([]byte, error) {
+	template := &x509.RevocationList{
+		Number: number,
+		ThisUpdate: now,
+		NextUpdate: now.Add(revocationListValidity),
+		RevokedCertificateEntries: entries,
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, root, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+func parseRevocationListPem(data []byte) (*x509.RevocationList, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no revocation list found in PEM data")
+	}
+	return x509.ParseRevocationList(block.Bytes)
+}
+
+//Re-sign revocation list, optionally adding a revoked serial number, and store it in CA secret
+func (ca *internalCA) updateRevocationList(ctx context.Context, secret *apiv1.Secret, root *x509.Certificate, key crypto.Signer, revoked *big.Int, now time.Time) ([]byte, error) {
+	entries := make([]x509.RevocationListEntry, 0)
+	number := big.NewInt(1)
+
+	current, err := parseRevocationListPem(secret.Data[revocationListSecretKey])
+	if err == nil {
+		entries = append(entries, current.RevokedCertificateEntries...)
+		number = new(big.Int).Add(current.Number, big.NewInt(1))
+	}
+
+	if revoked != nil {
+		for _, entry := range entries {
+			if entry.SerialNumber.Cmp(revoked) == 0 {
+				return nil, status.Errorf(codes.AlreadyExists, "certificate %x already revoked", revoked)
+			}
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: revoked, RevocationTime: now})
+	}
+
+	crl, err := createRevocationList(root, key, entries, number, now)
+	if err != nil {
+		return nil, err
+	}
+
+	secret.Data[revocationListSecretKey] = crl
+	_, err = ca.kubeAPI.CoreV1().Secrets(ca.namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return crl, nil
+}
+
+//Issue certificate for given hostnames, returns PEM encoded certificate, private key and CA certificate
+func (ca *internalCA) issue(ctx context.Context, hostnames []string) ([]byte, []byte, []byte, error) {
+	_, root, caKey, err := ca.load(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{CommonName: hostnames[0]},
+		DNSNames: hostnames,
+		NotBefore: now.Add(-time.Hour),
+		NotAfter: now.Add(internalCertificateValidity),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, root, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyPem, err := encodePrivateKeyPem(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return encodeCertificatePem(der), keyPem, encodeCertificatePem(root.Raw), nil
+}
+
+//Add certificate to the revocation list
+func (ca *internalCA) revoke(ctx context.Context, cert *x509.Certificate) error {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	secret, root, key, err := ca.load(ctx)
+	if err != nil {
+		return err
+	}
+	if err = cert.CheckSignatureFrom(root); err != nil {
+		return status.Errorf(codes.FailedPrecondition, "certificate was not issued by internal CA")
+	}
+
+	_, err = ca.updateRevocationList(ctx, secret, root, key, cert.SerialNumber, time.Now())
+	return err
+}
+
+//Return PEM encoded revocation list, re-signing it when it is about to become stale
+func (ca *internalCA) revocationList(ctx context.Context) ([]byte, error) {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	secret, root, key, err := ca.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	current, err := parseRevocationListPem(secret.Data[revocationListSecretKey])
+	if err == nil && now.Add(revocationListValidity / 2).Before(current.NextUpdate) {
+		return secret.Data[revocationListSecretKey], nil
+	}
+	return ca.updateRevocationList(ctx, secret, root, key, nil, now)
+}
+
+//Return PEM encoded root certificate
+func (ca *internalCA) certificate(ctx context.Context) ([]byte, error) {
+	_, root, _, err := ca.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return encodeCertificatePem(root.Raw), nil
+}
+
+//Check if TLS secret holds a certificate issued by the internal CA
+func isInternalCaCertificate(secret *apiv1.Secret) bool {
+	return secret.Annotations[certificateSourceAnnotation] == certificateSourceInternalCa
+}
+
+//Issue certificate and store it in instance TLS secret
+func (ca *internalCA) issueIntoSecret(ctx context.Context, namespace string, uid string, hostnames []string) (*x509.Certificate, error) {
+	certPem, keyPem, caPem, err := ca.issue(ctx, hostnames)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{certificateIssuerLabel: certificateSourceInternalCa}
+	annotations := map[string]string{certificateSourceAnnotation: certificateSourceInternalCa}
+	data := map[string][]byte{
+		apiv1.TLSCertKey: certPem,
+		apiv1.TLSPrivateKeyKey: keyPem,
+		caCertificateSecretKey: caPem,
+	}
+	err = writeInstanceTlsSecret(ctx, ca.kubeAPI, namespace, uid, labels, annotations, data)
+	if err != nil {
+		return nil, err
+	}
+	return parseLeafCertificate(certPem)
+}
+
+//Re-issue internal CA certificates which are close to expiry, returns the number of renewed certificates
+func (ca *internalCA) renewExpiring(ctx context.Context, now time.Time) int {
+	selector := certificateIssuerLabel + "=" + certificateSourceInternalCa
+	secrets, err := ca.kubeAPI.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		logLine(fmt.Sprintf("Could not retrieve internal CA certificates: %v", err))
+		return 0
+	}
+
+	renewed := 0
+	for _, secret := range secrets.Items {
+		cert, err := parseLeafCertificate(secret.Data[apiv1.TLSCertKey])
+		if err != nil || now.Add(internalCertificateRenewBefore).Before(cert.NotAfter) {
+			continue
+		}
+		uid := strings.TrimSuffix(secret.Name, "-tls")
+		logLine(fmt.Sprintf("Renewing internal CA certificate %s in namespace %s", secret.Name, secret.Namespace))
+		if _, err = ca.issueIntoSecret(ctx, secret.Namespace, uid, cert.DNSNames); err != nil {
+			logLine(fmt.Sprintf("Error occurred while renewing certificate %s: %v", secret.Name, err))
+			continue
+		}
+		renewed++
+	}
+	return renewed
+}
+
+//RunInternalCaRenewer periodically re-issues internal CA certificates before they expire
+func RunInternalCaRenewer(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, interval time.Duration) {
+	ca := newInternalCA(kubeAPI, namespace)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ca.renewExpiring(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	"crypto/x509"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestCertManagerServiceServer_CreateOrReplaceWithInternalCa(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewCertManagerServiceServer(client, newFakeDynamicClient(), "nmaas-system")
+
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Pass without issuer name
+	certReq := v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: []string{"tool.example.com"}, IssuerKind: internalCaIssuerKind}
+	res, err := server.CreateOrReplace(context.Background(), &certReq)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+
+	//issued certificate should chain up to the internal CA
+	caRes, err := server.RetrieveCaCertificate(context.Background(), &v1.InternalCaRequest{Api: apiVersion})
+	if err != nil || caRes.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(caRes.Info))
+
+	secret, err := client.CoreV1().Secrets("test-namespace").Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	if err != nil || !isInternalCaCertificate(secret) || secret.Type != corev1.SecretTypeTLS {
+		t.Fatal(err)
+	}
+	cert, err := parseLeafCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "tool.example.com"}); err != nil {
+		t.Error(err)
+	}
+
+	//CA secret is created in its own namespace
+	_, err = client.CoreV1().Secrets("nmaas-system").Get(context.Background(), internalCaSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fail()
+	}
+
+	//Status is reported from the secret
+	statusRes, err := server.GetStatus(context.Background(), &req)
+	if err != nil || statusRes.Status != v1.Status_OK || !statusRes.Ready || statusRes.Reason != "IssuedByInternalCA" {
+		t.Fail()
+	}
+}
+
+func TestCertManagerServiceServer_RenewAndRevokeWithInternalCa(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewCertManagerServiceServer(client, newFakeDynamicClient(), "nmaas-system")
+
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on revoking certificate not issued by internal CA
+	createTestTlsSecret(t, client, "test-namespace", "test-uid-tls", nil, time.Now().Add(24*time.Hour))
+	res, err := server.Revoke(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	certReq := v1.CertificateRequest{Api: apiVersion, Instance: &inst, Hostnames: []string{"tool.example.com"}, IssuerKind: internalCaIssuerKind}
+	_, _ = server.CreateOrReplace(context.Background(), &certReq)
+
+	//Renewal re-issues certificate immediately
+	stream := newFakeServerStream[v1.CertificateRenewalResponse]()
+	err = server.Renew(&v1.CertificateRenewalRequest{Api: apiVersion, Instance: &inst}, stream)
+	renewRes := <-stream.responses
+	if err != nil || renewRes.Status != v1.Status_OK || renewRes.SerialNumber == renewRes.PreviousSerialNumber {
+		t.Fail()
+	}
+
+	//Pass
+	res, err = server.Revoke(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+
+	//Fail on revoking the same certificate twice
+	res, err = server.Revoke(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	crlRes, err := server.RetrieveRevocationList(context.Background(), &v1.InternalCaRequest{Api: apiVersion})
+	if err != nil || crlRes.Status != v1.Status_OK {
+		t.Fatal(err)
+	}
+	crl, err := parseRevocationListPem([]byte(crlRes.Info))
+	if err != nil || len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatal(err)
+	}
+	if crl.RevokedCertificateEntries[0].SerialNumber.Text(16) != renewRes.SerialNumber {
+		t.Fail()
+	}
+}
+
+func TestInternalCA_RenewExpiring(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	ca := newInternalCA(client, "nmaas-system")
+
+	first, err := ca.issueIntoSecret(context.Background(), "test-namespace", "test-uid", []string{"tool.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Nothing to renew yet
+	if ca.renewExpiring(context.Background(), time.Now()) != 0 {
+		t.Fail()
+	}
+
+	//Renew certificates close to expiry
+	if ca.renewExpiring(context.Background(), time.Now().Add(internalCertificateValidity - 24*time.Hour)) != 1 {
+		t.Fail()
+	}
+
+	secret, _ := client.CoreV1().Secrets("test-namespace").Get(context.Background(), "test-uid-tls", metav1.GetOptions{})
+	renewed, err := parseLeafCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil || renewed.SerialNumber.Cmp(first.SerialNumber) == 0 || renewed.DNSNames[0] != "tool.example.com" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"regexp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	maxLogContextLines = 100
+)
+
+//Level keywords used by common logging libraries, matched as whole words so that e.g. "errors=0" is not reported
+var errorSeverityPattern = regexp.MustCompile(`(?i)\b(fatal|panic|crit|critical|error|err|severe|emerg|emergency|alert)\b`)
+var warningSeverityPattern = regexp.MustCompile(`(?i)\b(warn|warning)\b`)
+
+//Guess severity of log line from the level keywords it contains
+func detectLogSeverity(line string) v1.LogSeverity {
+	if errorSeverityPattern.MatchString(line) {
+		return v1.LogSeverity_ERROR
+	}
+	if warningSeverityPattern.MatchString(line) {
+		return v1.LogSeverity_WARNING
+	}
+	return v1.LogSeverity_ANY
+}
+
+//Filter applied to log lines of a single container while they are read, keeping track of context lines
+type logFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	minSeverity v1.LogSeverity
+	contextLines int
+	before []*v1.PodLogLine
+	after int
+}
+
+func compileLogPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid log filter pattern %s: %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+//Prepare filter for log lines, nil filter lets every line through
+func newLogFilter(filter *v1.LogFilter) (*logFilter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	include, err := compileLogPatterns(filter.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileLogPatterns(filter.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	if filter.ContextLines < 0 || filter.ContextLines > maxLogContextLines {
+		return nil, status.Errorf(codes.InvalidArgument, "number of context lines must be between 0 and %d", maxLogContextLines)
+	}
+	return &logFilter{include: include, exclude: exclude, minSeverity: filter.MinSeverity, contextLines: int(filter.ContextLines)}, nil
+}
+
+func (f *logFilter) matches(line *v1.PodLogLine) bool {
+	if line.Severity < f.minSeverity {
+		return false
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(line.Line) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(line.Line) {
+			return true
+		}
+	}
+	return false
+}
+
+//Return lines which should be sent for given line, preceded by context lines held back so far
+func (f *logFilter) apply(line *v1.PodLogLine) []*v1.PodLogLine {
+	line.Severity = detectLogSeverity(line.Line)
+	if f == nil {
+		return []*v1.PodLogLine{line}
+	}
+
+	if f.matches(line) {
+		lines := append(f.before, line)
+		f.before = nil
+		f.after = f.contextLines
+		return lines
+	}
+
+	line.ContextLine = true
+	if f.after > 0 {
+		f.after--
+		return []*v1.PodLogLine{line}
+	}
+	if f.contextLines > 0 {
+		f.before = append(f.before, line)
+		if len(f.before) > f.contextLines {
+			f.before = f.before[1:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"strings"
+	"testing"
+)
+
+const testLogs = `2024-03-01T10:00:00Z INFO starting server
+2024-03-01T10:00:01Z DEBUG loading config
+2024-03-01T10:00:02Z level=warn msg="deprecated option used"
+2024-03-01T10:00:03Z INFO listening on :8080
+2024-03-01T10:00:04Z GET /health 200
+2024-03-01T10:00:05Z ERROR connection refused by database
+2024-03-01T10:00:06Z INFO retrying in 5s
+2024-03-01T10:00:07Z INFO errors=0 processed=10
+2024-03-01T10:00:08Z GET /health 200
+`
+
+func filterTestLogs(t *testing.T, filter *v1.LogFilter) []*v1.PodLogLine {
+	f, err := newLogFilter(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]*v1.PodLogLine, 0)
+	err = forwardLogLines(strings.NewReader(testLogs), "test-uid-pod", "app", 1024, f, func(line *v1.PodLogLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestDetectLogSeverity(t *testing.T) {
+	if detectLogSeverity("[ERROR] something broke") != v1.LogSeverity_ERROR || detectLogSeverity("panic: runtime error") != v1.LogSeverity_ERROR {
+		t.Fail()
+	}
+	if detectLogSeverity("W0301 level=warning msg=slow") != v1.LogSeverity_WARNING {
+		t.Fail()
+	}
+	if detectLogSeverity("INFO errors=0 terrorist=false") != v1.LogSeverity_ANY {
+		t.Fail()
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	//Pass without filter
+	lines := filterTestLogs(t, nil)
+	if len(lines) != 9 || lines[5].Severity != v1.LogSeverity_ERROR || lines[2].Severity != v1.LogSeverity_WARNING {
+		t.Fail()
+	}
+
+	//Pass with severity
+	lines = filterTestLogs(t, &v1.LogFilter{MinSeverity: v1.LogSeverity_WARNING})
+	if len(lines) != 2 || lines[0].Timestamp != "2024-03-01T10:00:02Z" || lines[1].Line != "ERROR connection refused by database" {
+		t.Fail()
+	}
+
+	//Pass with include and exclude patterns
+	lines = filterTestLogs(t, &v1.LogFilter{Include: []string{"INFO", "GET"}, Exclude: []string{`/health`}})
+	if len(lines) != 4 || lines[0].Line != "INFO starting server" || lines[3].Line != "INFO errors=0 processed=10" {
+		t.Fail()
+	}
+
+	//Pass with context lines around errors
+	lines = filterTestLogs(t, &v1.LogFilter{MinSeverity: v1.LogSeverity_ERROR, ContextLines: 2})
+	if len(lines) != 5 || lines[0].Line != "INFO listening on :8080" || !lines[0].ContextLine || lines[2].ContextLine || lines[4].Line != "INFO errors=0 processed=10" {
+		t.Fail()
+	}
+
+	//Fail on invalid pattern
+	_, err := newLogFilter(&v1.LogFilter{Include: []string{"(unclosed"}})
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on too many context lines
+	_, err = newLogFilter(&v1.LogFilter{ContextLines: maxLogContextLines + 1})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestPodServiceServer_RetrievePodLogsWithFilter(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewPodServiceServer(client, nil)
+
+	//create mock namespace and pod
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+	p1 := corev1.Pod{}
+	p1.Name = "test-uid-pod"
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p1, metav1.CreateOptions{})
+
+	pod := v1.PodInfo{Name: "test-uid-pod"}
+
+	//Pass with matching filter
+	res, err := server.RetrievePodLogs(context.Background(), &v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst, Filter: &v1.LogFilter{Include: []string{"fake"}}})
+	if err != nil || res.Status != v1.Status_OK || len(res.Lines) != 1 {
+		t.Fail()
+	}
+
+	//Pass with filter excluding every line
+	res, err = server.RetrievePodLogs(context.Background(), &v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst, Filter: &v1.LogFilter{MinSeverity: v1.LogSeverity_ERROR}})
+	if err != nil || res.Status != v1.Status_OK || len(res.Lines) != 0 {
+		t.Fail()
+	}
+
+	//Fail on invalid filter
+	res, err = server.RetrievePodLogs(context.Background(), &v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst, Filter: &v1.LogFilter{Exclude: []string{"[z-a]"}}})
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+}
@@ -0,0 +1,285 @@
+package v1
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	fileTransferChunkSize = 64 * 1024
+	maxExecStderr = 4 * 1024
+)
+
+//Maximum size of a single file transferred from or to instance pod
+var maxFileTransferSize = int64(256 * 1024 * 1024)
+
+//Pseudo filesystems and device files which are never transferred
+var deniedTransferPaths = []string{"/proc", "/sys", "/dev"}
+
+//Prepare file chunk
+func prepareFileChunk(status v1.Status, message string) *v1.FileChunk {
+	return &v1.FileChunk {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+	}
+}
+
+//Prepare file transfer response
+func prepareFileTransferResponse(status v1.Status, message string) *v1.FileTransferResponse {
+	return &v1.FileTransferResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+	}
+}
+
+//Check that path names a file by absolute path without any parent directory references, outside of pseudo filesystems
+func validateTransferPath(filePath string) error {
+	if !path.IsAbs(filePath) || strings.HasSuffix(filePath, "/") {
+		return status.Errorf(codes.InvalidArgument, "path %s has to be an absolute path of a file", filePath)
+	}
+	for _, segment := range strings.Split(filePath, "/") {
+		if segment == ".." || segment == "." {
+			return status.Errorf(codes.InvalidArgument, "path %s must not contain relative segments", filePath)
+		}
+	}
+	cleaned := path.Clean(filePath)
+	for _, denied := range deniedTransferPaths {
+		if cleaned == denied || strings.HasPrefix(cleaned, denied + "/") {
+			return status.Errorf(codes.PermissionDenied, "path %s is not allowed", filePath)
+		}
+	}
+	return nil
+}
+
+//Keeps only the beginning of command error output so that a misbehaving command cannot exhaust memory
+type limitedBuffer struct {
+	buffer bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buffer.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buffer.Write(p[:remaining])
+		} else {
+			b.buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+//Run command in container through exec subresource, error output of command is reported in returned error
+func (s *podServiceServer) execInContainer(ctx context.Context, namespace string, pod string, container string, command []string, stdin io.Reader, stdout io.Writer) error {
+	opts := &apiv1.PodExecOptions{
+		Container: container,
+		Command: command,
+		Stdin: stdin != nil,
+		Stdout: true,
+		Stderr: true,
+	}
+	executor, err := s.newExecutor(namespace, pod, opts)
+	if err != nil {
+		return err
+	}
+	stderr := &limitedBuffer{limit: maxExecStderr}
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+	if err != nil && stderr.buffer.Len() > 0 {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.buffer.String()))
+	}
+	return err
+}
+
+func (s *podServiceServer) DownloadFile(req *v1.FileTransferRequest, stream v1.PodService_DownloadFileServer) error {
+	logLine("> Entered DownloadFile method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	depl := req.Instance
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareFileChunk(v1.Status_FAILED, namespaceNotFound))
+		return err
+	}
+
+	if err = validateTransferPath(req.Path); err != nil {
+		_ = stream.Send(prepareFileChunk(v1.Status_FAILED, "Invalid file path"))
+		return err
+	}
+
+	pod, container, err := findInstanceContainer(ctx, s.kubeAPI, depl, req.Pod, req.Container)
+	if err != nil {
+		_ = stream.Send(prepareFileChunk(v1.Status_FAILED, "Pod or container not found"))
+		return err
+	}
+
+	//file is packed by tar in the container, the same way kubectl cp does it
+	dir, name := path.Split(path.Clean(req.Path))
+	logLine(fmt.Sprintf("Downloading %s from pod/container %s/%s", req.Path, pod.Name, container))
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		_ = writer.CloseWithError(s.execInContainer(ctx, depl.Namespace, pod.Name, container, []string{"tar", "cf", "-", "-C", dir, name}, nil, writer))
+	}()
+
+	archive := tar.NewReader(reader)
+	header, err := archive.Next()
+	if err != nil {
+		_ = stream.Send(prepareFileChunk(v1.Status_FAILED, fmt.Sprintf("Issue with reading file: %v", err)))
+		return status.Errorf(codes.NotFound, "file %s could not be read: %v", req.Path, err)
+	}
+	if header.Typeflag != tar.TypeReg || path.Clean(header.Name) != name {
+		_ = stream.Send(prepareFileChunk(v1.Status_FAILED, "Not a regular file"))
+		return status.Errorf(codes.FailedPrecondition, "%s is not a regular file", req.Path)
+	}
+	if header.Size > maxFileTransferSize {
+		_ = stream.Send(prepareFileChunk(v1.Status_FAILED, "File too large"))
+		return status.Errorf(codes.ResourceExhausted, "file %s has %d bytes, limit is %d", req.Path, header.Size, maxFileTransferSize)
+	}
+
+	offset := int64(0)
+	buffer := make([]byte, fileTransferChunkSize)
+	for {
+		n, err := io.ReadFull(archive, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			_ = stream.Send(prepareFileChunk(v1.Status_FAILED, fmt.Sprintf("Issue with reading file: %v", err)))
+			return err
+		}
+		chunk := prepareFileChunk(v1.Status_OK, "")
+		chunk.Size, chunk.Offset, chunk.Data = header.Size, offset, append([]byte{}, buffer[:n]...)
+		offset += int64(n)
+		chunk.Last = offset >= header.Size
+		if err != nil && !chunk.Last {
+			_ = stream.Send(prepareFileChunk(v1.Status_FAILED, "File ended prematurely"))
+			return status.Errorf(codes.DataLoss, "received %d of %d bytes of %s", offset, header.Size, req.Path)
+		}
+		if sendErr := stream.Send(chunk); sendErr != nil {
+			return sendErr
+		}
+		if chunk.Last {
+			break
+		}
+	}
+
+	logLine(fmt.Sprintf("< Sent %d bytes of %s", offset, req.Path))
+	return nil
+}
+
+func (s *podServiceServer) UploadFile(stream v1.PodService_UploadFileServer) error {
+	logLine("> Entered UploadFile method")
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	//first message describes target file and may already carry data
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	depl := req.Instance
+	if depl == nil {
+		return status.Errorf(codes.InvalidArgument, "instance has to be given in the first upload message")
+	}
+
+	//check if given k8s namespace exists
+	_, err = s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.SendAndClose(prepareFileTransferResponse(v1.Status_FAILED, namespaceNotFound))
+		return err
+	}
+
+	if err = validateTransferPath(req.Path); err != nil {
+		_ = stream.SendAndClose(prepareFileTransferResponse(v1.Status_FAILED, "Invalid file path"))
+		return err
+	}
+	if req.Size < 0 || req.Size > maxFileTransferSize {
+		_ = stream.SendAndClose(prepareFileTransferResponse(v1.Status_FAILED, "File too large"))
+		return status.Errorf(codes.ResourceExhausted, "file size must be between 0 and %d bytes", maxFileTransferSize)
+	}
+
+	pod, container, err := findInstanceContainer(ctx, s.kubeAPI, depl, req.Pod, req.Container)
+	if err != nil {
+		_ = stream.SendAndClose(prepareFileTransferResponse(v1.Status_FAILED, "Pod or container not found"))
+		return err
+	}
+
+	//file is unpacked by tar in the container, size has to be known upfront to write the tar header
+	filePath, size := req.Path, req.Size
+	dir, name := path.Split(path.Clean(filePath))
+	logLine(fmt.Sprintf("Uploading %d bytes to %s in pod/container %s/%s", size, filePath, pod.Name, container))
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := s.execInContainer(ctx, depl.Namespace, pod.Name, container, []string{"tar", "xf", "-", "-C", dir}, reader, io.Discard)
+		_ = reader.CloseWithError(err)
+		done <- err
+	}()
+
+	archive := tar.NewWriter(writer)
+	written := int64(0)
+	err = archive.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: size, ModTime: time.Now()})
+	for err == nil {
+		if written += int64(len(req.Data)); written > size {
+			err = status.Errorf(codes.InvalidArgument, "received more than declared %d bytes", size)
+			break
+		}
+		if _, err = archive.Write(req.Data); err != nil {
+			break
+		}
+		if req, err = stream.Recv(); err == io.EOF {
+			err = nil
+			if written < size {
+				err = status.Errorf(codes.InvalidArgument, "received less than declared %d bytes", size)
+			}
+			break
+		}
+	}
+	if err != nil {
+		//stop tar in the container, it fails on the broken archive
+		_ = writer.CloseWithError(err)
+		cancel()
+		<-done
+		_ = stream.SendAndClose(prepareFileTransferResponse(v1.Status_FAILED, fmt.Sprintf("Issue with receiving file: %v", err)))
+		return err
+	}
+	//tar may exit as soon as it has the whole file, without reading the archive trailer
+	_ = archive.Close()
+	_ = writer.Close()
+
+	if err = <-done; err != nil {
+		_ = stream.SendAndClose(prepareFileTransferResponse(v1.Status_FAILED, fmt.Sprintf("Issue with writing file: %v", err)))
+		return err
+	}
+
+	res := prepareFileTransferResponse(v1.Status_OK, "File uploaded")
+	res.Size = size
+	logLine(fmt.Sprintf("< Uploaded %d bytes to %s", size, filePath))
+	return stream.SendAndClose(res)
+}
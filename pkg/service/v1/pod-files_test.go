@@ -0,0 +1,270 @@
+package v1
+
+import (
+	"archive/tar"
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"bytes"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/remotecommand"
+	"path"
+	"sync"
+	"testing"
+)
+
+//Executor emulating tar in container with files kept in memory, symlinks are kept as targets
+type fakeTarExecutor struct {
+	mutex sync.Mutex
+	command []string
+	files map[string][]byte
+	links map[string]string
+}
+
+func (f *fakeTarExecutor) Stream(options remotecommand.StreamOptions) error {
+	return f.StreamWithContext(context.Background(), options)
+}
+
+func (f *fakeTarExecutor) StreamWithContext(ctx context.Context, options remotecommand.StreamOptions) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	dir := f.command[4]
+	if f.command[1] == "cf" {
+		name := f.command[5]
+		archive := tar.NewWriter(options.Stdout)
+		if target, ok := f.links[path.Join(dir, name)]; ok {
+			_ = archive.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: name, Linkname: target})
+			return archive.Close()
+		}
+		content, ok := f.files[path.Join(dir, name)]
+		if !ok {
+			_, _ = options.Stderr.Write([]byte("tar: " + name + ": No such file or directory"))
+			return errors.New("command terminated with non-zero exit code")
+		}
+		_ = archive.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(content)), Mode: 0644})
+		_, _ = archive.Write(content)
+		return archive.Close()
+	}
+	archive := tar.NewReader(options.Stdin)
+	header, err := archive.Next()
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(archive)
+	if err != nil {
+		return err
+	}
+	f.files[path.Join(dir, header.Name)] = content
+	return nil
+}
+
+type fakeUploadStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	requests []*v1.FileTransferRequest
+	response *v1.FileTransferResponse
+}
+
+func (f *fakeUploadStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeUploadStream) Recv() (*v1.FileTransferRequest, error) {
+	if len(f.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := f.requests[0]
+	f.requests = f.requests[1:]
+	return req, nil
+}
+
+func (f *fakeUploadStream) SendAndClose(res *v1.FileTransferResponse) error {
+	f.response = res
+	return nil
+}
+
+func newFileTransferTestServer(t *testing.T) (*podServiceServer, *fakeTarExecutor) {
+	client := testclient.NewSimpleClientset()
+	executor := &fakeTarExecutor{files: map[string][]byte{}, links: map[string]string{}}
+	server := &podServiceServer{kubeAPI: client, newExecutor: func(namespace string, pod string, opts *corev1.PodExecOptions) (remotecommand.Executor, error) {
+		if opts.Container != "app" {
+			t.Fail()
+		}
+		executor.command = opts.Command
+		return executor, nil
+	}}
+	return server, executor
+}
+
+func createFileTransferPod(server *podServiceServer) {
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = server.kubeAPI.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+	p1 := corev1.Pod{}
+	p1.Name = "test-uid-0"
+	p1.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	p1.Spec.Containers = []corev1.Container{{Name: "app"}}
+	_, _ = server.kubeAPI.CoreV1().Pods("test-namespace").Create(context.Background(), &p1, metav1.CreateOptions{})
+}
+
+func TestPodServiceServer_DownloadFile(t *testing.T) {
+	fileTransferReq := v1.FileTransferRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Path: "/var/backups/dump.sql"}
+	server, executor := newFileTransferTestServer(t)
+
+	//Fail on API version check
+	err := server.DownloadFile(&v1.FileTransferRequest{Api: "dummy", Instance: &inst}, &fakeServerStream[v1.FileChunk]{ctx: context.Background()})
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	stream := &fakeServerStream[v1.FileChunk]{ctx: context.Background()}
+	err = server.DownloadFile(&fileTransferReq, stream)
+	if err == nil || stream.sent[0].Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	createFileTransferPod(server)
+
+	//Fail on path outside of sandbox
+	stream = &fakeServerStream[v1.FileChunk]{ctx: context.Background()}
+	err = server.DownloadFile(&v1.FileTransferRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Path: "/var/../proc/1/environ"}, stream)
+	if err == nil || stream.sent[0].Message != "Invalid file path" {
+		t.Fail()
+	}
+
+	//Fail on missing file
+	stream = &fakeServerStream[v1.FileChunk]{ctx: context.Background()}
+	err = server.DownloadFile(&fileTransferReq, stream)
+	if err == nil || stream.sent[0].Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Fail on symlink
+	executor.links["/var/backups/dump.sql"] = "/etc/shadow"
+	stream = &fakeServerStream[v1.FileChunk]{ctx: context.Background()}
+	err = server.DownloadFile(&fileTransferReq, stream)
+	if err == nil || stream.sent[0].Message != "Not a regular file" {
+		t.Fail()
+	}
+	delete(executor.links, "/var/backups/dump.sql")
+
+	//Fail on file too large
+	content := bytes.Repeat([]byte("0123456789"), fileTransferChunkSize / 5 + 1)
+	executor.files["/var/backups/dump.sql"] = content
+	previousMaxSize := maxFileTransferSize
+	t.Cleanup(func() { maxFileTransferSize = previousMaxSize })
+	maxFileTransferSize = int64(len(content) - 1)
+	stream = &fakeServerStream[v1.FileChunk]{ctx: context.Background()}
+	err = server.DownloadFile(&fileTransferReq, stream)
+	if err == nil || stream.sent[0].Message != "File too large" {
+		t.Fail()
+	}
+	maxFileTransferSize = previousMaxSize
+
+	//Pass
+	stream = &fakeServerStream[v1.FileChunk]{ctx: context.Background()}
+	err = server.DownloadFile(&fileTransferReq, stream)
+	if err != nil || len(stream.sent) != 3 {
+		t.Fatal(err)
+	}
+	received := make([]byte, 0)
+	for _, chunk := range stream.sent {
+		if chunk.Offset != int64(len(received)) || chunk.Size != int64(len(content)) {
+			t.Fail()
+		}
+		received = append(received, chunk.Data...)
+	}
+	if !bytes.Equal(received, content) || !stream.sent[2].Last || stream.sent[1].Last {
+		t.Fail()
+	}
+	if executor.command[4] != "/var/backups/" || executor.command[5] != "dump.sql" {
+		t.Fail()
+	}
+
+	//Pass with empty file
+	executor.files["/var/backups/dump.sql"] = []byte{}
+	stream = &fakeServerStream[v1.FileChunk]{ctx: context.Background()}
+	err = server.DownloadFile(&fileTransferReq, stream)
+	if err != nil || len(stream.sent) != 1 || !stream.sent[0].Last {
+		t.Fail()
+	}
+}
+
+func TestPodServiceServer_UploadFile(t *testing.T) {
+	server, executor := newFileTransferTestServer(t)
+	first := v1.FileTransferRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Path: "/srv/dashboards/main.json", Size: 11, Data: []byte("hello")}
+
+	//Fail on API version check
+	err := server.UploadFile(&fakeUploadStream{ctx: context.Background(), requests: []*v1.FileTransferRequest{{Api: "dummy", Instance: &inst}}})
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	stream := &fakeUploadStream{ctx: context.Background(), requests: []*v1.FileTransferRequest{&first}}
+	err = server.UploadFile(stream)
+	if err == nil || stream.response.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	createFileTransferPod(server)
+
+	//Fail on relative path
+	stream = &fakeUploadStream{ctx: context.Background(), requests: []*v1.FileTransferRequest{{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Path: "main.json"}}}
+	err = server.UploadFile(stream)
+	if err == nil || stream.response.Message != "Invalid file path" {
+		t.Fail()
+	}
+
+	//Fail on declared size over limit
+	stream = &fakeUploadStream{ctx: context.Background(), requests: []*v1.FileTransferRequest{{Api: apiVersion, Instance: &inst, Pod: "test-uid-0", Path: "/srv/main.json", Size: maxFileTransferSize + 1}}}
+	err = server.UploadFile(stream)
+	if err == nil || stream.response.Message != "File too large" {
+		t.Fail()
+	}
+
+	//Fail on more data than declared
+	stream = &fakeUploadStream{ctx: context.Background(), requests: []*v1.FileTransferRequest{&first, {Data: []byte(" world and more")}}}
+	err = server.UploadFile(stream)
+	if err == nil || stream.response.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Fail on less data than declared
+	stream = &fakeUploadStream{ctx: context.Background(), requests: []*v1.FileTransferRequest{&first}}
+	err = server.UploadFile(stream)
+	if err == nil || stream.response.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+	if _, ok := executor.files["/srv/dashboards/main.json"]; ok {
+		t.Fail()
+	}
+
+	//Pass
+	stream = &fakeUploadStream{ctx: context.Background(), requests: []*v1.FileTransferRequest{&first, {Data: []byte(" wor")}, {Data: []byte("ld")}}}
+	err = server.UploadFile(stream)
+	if err != nil || stream.response.Status != v1.Status_OK || stream.response.Size != 11 {
+		t.Fatal(err)
+	}
+	if string(executor.files["/srv/dashboards/main.json"]) != "hello world" || executor.command[1] != "xf" {
+		t.Fail()
+	}
+}
+
+func TestValidateTransferPath(t *testing.T) {
+	for _, valid := range []string{"/tmp/report.pdf", "/var/lib/grafana/dashboards/main.json", "/development.log"} {
+		if validateTransferPath(valid) != nil {
+			t.Fail()
+		}
+	}
+	for _, invalid := range []string{"", "report.pdf", "/tmp/", "/", "/tmp/../etc/passwd", "/tmp/./x", "/proc/self/environ", "/sys", "/dev/sda"} {
+		if validateTransferPath(invalid) == nil {
+			t.Fail()
+		}
+	}
+}
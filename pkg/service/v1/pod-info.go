@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+//Retrieve pods selected by instance workloads, as well as pods labelled with the instance, sorted by name
+func findInstancePods(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, uid string) ([]apiv1.Pod, error) {
+	workloads, err := findInstanceWorkloads(ctx, kubeAPI, namespace, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	selectors := []string{fmt.Sprintf("%s=%s", instanceLabel, uid)}
+	for _, workload := range workloads {
+		if workload.selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(workload.selector)
+		if err != nil || selector.Empty() {
+			//empty selector would match every pod in the namespace
+			continue
+		}
+		selectors = append(selectors, selector.String())
+	}
+
+	found := make(map[string]apiv1.Pod)
+	for _, selector := range selectors {
+		pods, err := kubeAPI.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			found[pod.Name] = pod
+		}
+	}
+
+	matching := make([]apiv1.Pod, 0, len(found))
+	for _, pod := range found {
+		matching = append(matching, pod)
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].Name < matching[j].Name
+	})
+	return matching, nil
+}
+
+//Find pod by name among pods belonging to instance
+func findInstancePod(ctx context.Context, kubeAPI kubernetes.Interface, depl *v1.Instance, podName string) (*apiv1.Pod, error) {
+	pods, err := findInstancePods(ctx, kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		if pods[i].Name == podName {
+			return &pods[i], nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "pod %s does not belong to instance %s", podName, depl.Uid)
+}
+
+//Find pod belonging to instance and resolve container in it, first container of pod is used when none is given
+func findInstanceContainer(ctx context.Context, kubeAPI kubernetes.Interface, depl *v1.Instance, podName string, container string) (*apiv1.Pod, string, error) {
+	pod, err := findInstancePod(ctx, kubeAPI, depl, podName)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(container) == 0 && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			return pod, container, nil
+		}
+	}
+	return nil, "", status.Errorf(codes.NotFound, "container %s not found in pod %s", container, podName)
+}
+
+//Describe state of a container the way kubectl does, together with the reason it is waiting or terminated
+func describeContainerState(state apiv1.ContainerState) (string, string) {
+	switch {
+	case state.Running != nil:
+		return "Running", ""
+	case state.Waiting != nil:
+		return "Waiting", state.Waiting.Reason
+	case state.Terminated != nil:
+		return "Terminated", state.Terminated.Reason
+	}
+	return "Unknown", ""
+}
+
+//Describe containers of pod spec, completed with their current status when it is known
+func describeContainers(containers []apiv1.Container, statuses []apiv1.ContainerStatus) []*v1.ContainerInfo {
+	byName := make(map[string]apiv1.ContainerStatus)
+	for _, cs := range statuses {
+		byName[cs.Name] = cs
+	}
+
+	infos := make([]*v1.ContainerInfo, 0, len(containers))
+	for _, container := range containers {
+		info := &v1.ContainerInfo{Name: container.Name, Image: container.Image, State: "Unknown"}
+		if cs, ok := byName[container.Name]; ok {
+			info.Ready = cs.Ready
+			info.RestartCount = cs.RestartCount
+			info.State, info.Reason = describeContainerState(cs.State)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+//Describe pod with its phase, readiness, restarts and containers
+func describePod(pod *apiv1.Pod) *v1.PodInfo {
+	info := &v1.PodInfo{
+		Name: pod.Name,
+		DisplayName: pod.Name,
+		Containers: make([]string, 0, len(pod.Spec.Containers)),
+		Phase: string(pod.Status.Phase),
+		Node: pod.Spec.NodeName,
+		ContainerDetails: describeContainers(pod.Spec.Containers, pod.Status.ContainerStatuses),
+		InitContainers: describeContainers(pod.Spec.InitContainers, pod.Status.InitContainerStatuses),
+	}
+	for _, container := range pod.Spec.Containers {
+		info.Containers = append(info.Containers, container.Name)
+	}
+	for _, container := range info.ContainerDetails {
+		info.RestartCount += container.RestartCount
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == apiv1.PodReady {
+			info.Ready = condition.Status == apiv1.ConditionTrue
+		}
+	}
+	if pod.Status.StartTime != nil {
+		info.StartTime = pod.Status.StartTime.UTC().Format(time.RFC3339)
+	}
+	return info
+}
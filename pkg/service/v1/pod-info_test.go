@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestFindInstancePods(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+
+	//create mock deployment selecting pods by custom label
+	d := appsv1.Deployment{}
+	d.Name = "test-uid"
+	d.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &d, metav1.CreateOptions{})
+
+	//create mock pods, selected one does not share the instance name prefix
+	p1 := corev1.Pod{}
+	p1.Name = "web-abc12"
+	p1.Labels = map[string]string{"app": "web"}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p1, metav1.CreateOptions{})
+	p2 := corev1.Pod{}
+	p2.Name = "cache-0"
+	p2.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p2, metav1.CreateOptions{})
+	p3 := corev1.Pod{}
+	p3.Name = "test-uid2-0"
+	p3.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid2"}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p3, metav1.CreateOptions{})
+
+	//Pass
+	pods, err := findInstancePods(context.Background(), client, "test-namespace", "test-uid")
+	if err != nil || len(pods) != 2 {
+		t.Fatal(err)
+	}
+	if pods[0].Name != "cache-0" || pods[1].Name != "web-abc12" {
+		t.Fail()
+	}
+}
+
+func TestDescribePod(t *testing.T) {
+	started := metav1.NewTime(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	pod := corev1.Pod{}
+	pod.Name = "test-uid-web-0"
+	pod.Spec.NodeName = "node-1"
+	pod.Spec.InitContainers = []corev1.Container{{Name: "migrate", Image: "migrate:1.0"}}
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "app:2.1"}, {Name: "proxy", Image: "nginx:1.25"}}
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.StartTime = &started
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}}
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{Name: "migrate", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}}},
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", Ready: true, RestartCount: 1, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{Name: "proxy", RestartCount: 3, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+
+	info := describePod(&pod)
+	if info.Name != "test-uid-web-0" || info.Phase != "Running" || info.Ready || info.RestartCount != 4 {
+		t.Fail()
+	}
+	if info.Node != "node-1" || info.StartTime != "2024-03-01T10:00:00Z" || len(info.Containers) != 2 {
+		t.Fail()
+	}
+	if len(info.ContainerDetails) != 2 || info.ContainerDetails[0].Image != "app:2.1" || !info.ContainerDetails[0].Ready {
+		t.Fail()
+	}
+	if info.ContainerDetails[1].State != "Waiting" || info.ContainerDetails[1].Reason != "CrashLoopBackOff" {
+		t.Fail()
+	}
+	if len(info.InitContainers) != 1 || info.InitContainers[0].State != "Terminated" || info.InitContainers[0].Reason != "Completed" {
+		t.Fail()
+	}
+
+	//container without status yet
+	pod.Status.ContainerStatuses = nil
+	info = describePod(&pod)
+	if info.ContainerDetails[0].State != "Unknown" || info.RestartCount != 0 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+//Maximum number of log bytes sent over a single stream, so that a chatty container cannot be followed forever
+var podLogStreamByteBudget = int64(16 * 1024 * 1024)
+
+//Prepare pod log line
+func preparePodLogLine(status v1.Status, message string, pod string, container string, timestamp string, line string) *v1.PodLogLine {
+	return &v1.PodLogLine {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Pod: pod,
+		Container: container,
+		Timestamp: timestamp,
+		Line: line,
+	}
+}
+
+//Translate log options of pod request, zero values leave given option unset
+func preparePodLogOptions(req *v1.PodRequest) (*apiv1.PodLogOptions, error) {
+	opts := &apiv1.PodLogOptions{Previous: req.Previous, Timestamps: req.Timestamps}
+	if len(req.Pod.Containers) > 0 {
+		opts.Container = req.Pod.Containers[0]
+	}
+	if req.TailLines > 0 {
+		opts.TailLines = &req.TailLines
+	}
+	if req.LimitBytes > 0 {
+		opts.LimitBytes = &req.LimitBytes
+	}
+	if req.SinceSeconds > 0 && len(req.SinceTime) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "only one of sinceSeconds and sinceTime can be set")
+	}
+	if req.SinceSeconds > 0 {
+		opts.SinceSeconds = &req.SinceSeconds
+	}
+	if len(req.SinceTime) > 0 {
+		since, err := time.Parse(time.RFC3339, req.SinceTime)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "sinceTime %s is not a valid RFC3339 timestamp", req.SinceTime)
+		}
+		sinceTime := metav1.NewTime(since)
+		opts.SinceTime = &sinceTime
+	}
+	return opts, nil
+}
+
+//Split timestamp added by kubelet from log line, line is returned unchanged if it has no timestamp
+func splitLogTimestamp(line string) (string, string) {
+	prefix, rest, found := strings.Cut(line, " ")
+	if !found {
+		prefix, rest = line, ""
+	}
+	if _, err := time.Parse(time.RFC3339Nano, prefix); err != nil {
+		return "", line
+	}
+	return prefix, rest
+}
+
+//Read log stream line by line and pass on lines accepted by filter until stream ends or byte budget is exhausted
+func forwardLogLines(reader io.Reader, pod string, container string, budget int64, filter *logFilter, send func(*v1.PodLogLine) error) error {
+	buffered := bufio.NewReader(reader)
+	sent := int64(0)
+	for {
+		line, err := buffered.ReadString('\n')
+		if len(line) > 0 {
+			timestamp, text := splitLogTimestamp(strings.TrimRight(line, "\r\n"))
+			for _, accepted := range filter.apply(preparePodLogLine(v1.Status_OK, "", pod, container, timestamp, text)) {
+				sent += int64(len(accepted.Line))
+				if sent > budget {
+					_ = send(preparePodLogLine(v1.Status_FAILED, fmt.Sprintf("Log stream byte budget of %d bytes exhausted", budget), pod, container, "", ""))
+					return status.Errorf(codes.ResourceExhausted, "log stream of %s/%s exceeded %d bytes", pod, container, budget)
+				}
+				if sendErr := send(accepted); sendErr != nil {
+					return sendErr
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *podServiceServer) StreamPodLogs(req *v1.PodRequest, stream v1.PodService_StreamPodLogsServer) error {
+	logLine("> Entered StreamPodLogs method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	depl := req.Deployment
+	pod := req.Pod
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, namespaceNotFound, "", "", "", ""))
+		return err
+	}
+
+	//check if given pod exists
+	_, err = s.kubeAPI.CoreV1().Pods(depl.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, "Pod not found", pod.Name, "", "", ""))
+		return err
+	}
+
+	opts, err := preparePodLogOptions(req)
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, "Invalid log options", pod.Name, "", "", ""))
+		return err
+	}
+	//timestamps are always requested so that they can be sent separately
+	opts.Follow, opts.Timestamps = true, true
+
+	filter, err := newLogFilter(req.Filter)
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, "Invalid log filter", pod.Name, "", "", ""))
+		return err
+	}
+	logLine(fmt.Sprintf("Following logs of pod/container %s/%s in namespace %s", pod.Name, opts.Container, depl.Namespace))
+
+	podLogs, err := s.kubeAPI.CoreV1().Pods(depl.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		_ = stream.Send(preparePodLogLine(v1.Status_FAILED, "Issue with opening stream with logs", pod.Name, opts.Container, "", ""))
+		return err
+	}
+	defer podLogs.Close()
+
+	err = forwardLogLines(podLogs, pod.Name, opts.Container, podLogStreamByteBudget, filter, stream.Send)
+	if ctx.Err() != nil {
+		logLine(fmt.Sprintf("< Client stopped following logs of pod %s", pod.Name))
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	logLine(fmt.Sprintf("< Log stream of pod %s ended", pod.Name))
+	return nil
+}
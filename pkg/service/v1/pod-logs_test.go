@@ -0,0 +1,124 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPodServiceServer_StreamPodLogs(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewPodServiceServer(client, nil)
+
+	//Fail on API version check
+	pod := v1.PodInfo{Name: "test-uid-pod", Containers: []string{"app"}}
+	err := server.StreamPodLogs(&v1.PodRequest{Api: "dummy", Pod: &pod, Deployment: &inst}, &fakeServerStream[v1.PodLogLine]{ctx: context.Background()})
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	podReq := v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst}
+	stream := &fakeServerStream[v1.PodLogLine]{ctx: context.Background()}
+	err = server.StreamPodLogs(&podReq, stream)
+	if err == nil || len(stream.sent) != 1 || stream.sent[0].Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing pod
+	stream = &fakeServerStream[v1.PodLogLine]{ctx: context.Background()}
+	err = server.StreamPodLogs(&podReq, stream)
+	if err == nil || stream.sent[0].Message != "Pod not found" {
+		t.Fail()
+	}
+
+	//create mock pod
+	p1 := corev1.Pod{}
+	p1.Name = "test-uid-pod"
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &p1, metav1.CreateOptions{})
+
+	//Pass
+	stream = &fakeServerStream[v1.PodLogLine]{ctx: context.Background()}
+	err = server.StreamPodLogs(&podReq, stream)
+	if err != nil || len(stream.sent) != 1 || stream.sent[0].Line != "fake logs" || stream.sent[0].Container != "app" {
+		t.Fail()
+	}
+
+	//Stop when client cancels the stream
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = server.StreamPodLogs(&podReq, &fakeServerStream[v1.PodLogLine]{ctx: ctx})
+	if err != context.Canceled {
+		t.Fail()
+	}
+}
+
+func TestForwardLogLines(t *testing.T) {
+	logs := "2024-03-01T10:00:00.123456789Z starting server\n2024-03-01T10:00:01Z listening on :8080\nno timestamp here\n2024-03-01T10:00:02Z last line without newline"
+
+	lines := make([]*v1.PodLogLine, 0)
+	send := func(line *v1.PodLogLine) error {
+		lines = append(lines, line)
+		return nil
+	}
+
+	//Pass
+	err := forwardLogLines(strings.NewReader(logs), "test-uid-pod", "app", 1024, nil, send)
+	if err != nil || len(lines) != 4 {
+		t.Fatal(err)
+	}
+	if lines[0].Timestamp != "2024-03-01T10:00:00.123456789Z" || lines[0].Line != "starting server" || lines[0].Pod != "test-uid-pod" {
+		t.Fail()
+	}
+	if lines[2].Timestamp != "" || lines[2].Line != "no timestamp here" {
+		t.Fail()
+	}
+	if lines[3].Line != "last line without newline" {
+		t.Fail()
+	}
+
+	//Fail on exhausted byte budget
+	lines = make([]*v1.PodLogLine, 0)
+	err = forwardLogLines(strings.NewReader(logs), "test-uid-pod", "app", 40, nil, send)
+	if err == nil || len(lines) != 3 || lines[2].Status != v1.Status_FAILED {
+		t.Fail()
+	}
+}
+
+func TestPreparePodLogOptions(t *testing.T) {
+	pod := v1.PodInfo{Name: "test-uid-pod", Containers: []string{"app", "sidecar"}}
+
+	//Pass with defaults
+	opts, err := preparePodLogOptions(&v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst})
+	if err != nil || opts.Container != "app" || opts.TailLines != nil || opts.SinceSeconds != nil || opts.SinceTime != nil || opts.LimitBytes != nil || opts.Previous {
+		t.Fail()
+	}
+
+	//Pass with all options
+	opts, err = preparePodLogOptions(&v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst, TailLines: 100, SinceTime: "2024-03-01T10:00:00Z", LimitBytes: 4096, Previous: true, Timestamps: true})
+	if err != nil || *opts.TailLines != 100 || *opts.LimitBytes != 4096 || !opts.Previous || !opts.Timestamps || opts.SinceTime.UTC().Format(time.RFC3339) != "2024-03-01T10:00:00Z" {
+		t.Fail()
+	}
+
+	//Fail on both since options set
+	_, err = preparePodLogOptions(&v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst, SinceSeconds: 60, SinceTime: "2024-03-01T10:00:00Z"})
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on malformed since time
+	_, err = preparePodLogOptions(&v1.PodRequest{Api: apiVersion, Pod: &pod, Deployment: &inst, SinceTime: "yesterday"})
+	if err == nil {
+		t.Fail()
+	}
+}
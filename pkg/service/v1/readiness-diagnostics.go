@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	maxWarningEvents = 10
+	recentEventsWindow = time.Hour
+)
+
+//Waiting reasons which are part of a normal pod startup and not worth reporting
+var transientWaitingReasons = map[string]bool{
+	"ContainerCreating": true,
+	"PodInitializing": true,
+}
+
+//Prepare readiness response
+func prepareReadinessResponse(status v1.Status, message string, diagnostics []*v1.Diagnostic) *v1.ReadinessResponse {
+	return &v1.ReadinessResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Diagnostics: diagnostics,
+	}
+}
+
+//Retrieve pods matching workload selector
+func findWorkloadPods(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, selector *metav1.LabelSelector) ([]apiv1.Pod, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := kubeAPI.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+//Explain why containers of given pod are not ready
+func diagnoseContainers(pod *apiv1.Pod, statuses []apiv1.ContainerStatus) []*v1.Diagnostic {
+	diagnostics := make([]*v1.Diagnostic, 0)
+	for _, cs := range statuses {
+		if waiting := cs.State.Waiting; waiting != nil && !transientWaitingReasons[waiting.Reason] {
+			message := waiting.Message
+			if last := cs.LastTerminationState.Terminated; last != nil {
+				message = fmt.Sprintf("%s (last exit code %d: %s)", message, last.ExitCode, last.Reason)
+			}
+			diagnostics = append(diagnostics, &v1.Diagnostic{
+				Kind: "Pod",
+				Object: pod.Name,
+				Container: cs.Name,
+				Reason: waiting.Reason,
+				Message: message,
+				Count: cs.RestartCount,
+			})
+			continue
+		}
+		if terminated := cs.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			diagnostics = append(diagnostics, &v1.Diagnostic{
+				Kind: "Pod",
+				Object: pod.Name,
+				Container: cs.Name,
+				Reason: terminated.Reason,
+				Message: fmt.Sprintf("exited with code %d %s", terminated.ExitCode, terminated.Message),
+				Count: cs.RestartCount,
+			})
+			continue
+		}
+		if cs.State.Running != nil && !cs.Ready {
+			diagnostics = append(diagnostics, &v1.Diagnostic{
+				Kind: "Pod",
+				Object: pod.Name,
+				Container: cs.Name,
+				Reason: "ContainerNotReady",
+				Message: "container is running but its readiness probe has not succeeded yet",
+				Count: cs.RestartCount,
+			})
+		}
+	}
+	return diagnostics
+}
+
+//Explain why given pod is not ready
+func diagnosePod(pod *apiv1.Pod) []*v1.Diagnostic {
+	diagnostics := make([]*v1.Diagnostic, 0)
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == apiv1.PodScheduled && condition.Status == apiv1.ConditionFalse {
+			diagnostics = append(diagnostics, &v1.Diagnostic{
+				Kind: "Pod",
+				Object: pod.Name,
+				Reason: condition.Reason,
+				Message: condition.Message,
+			})
+		}
+	}
+	diagnostics = append(diagnostics, diagnoseContainers(pod, pod.Status.InitContainerStatuses)...)
+	diagnostics = append(diagnostics, diagnoseContainers(pod, pod.Status.ContainerStatuses)...)
+	return diagnostics
+}
+
+func eventTime(event *apiv1.Event) time.Time {
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.FirstTimestamp.IsZero() {
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+//Check if event concerns one of given objects or a ReplicaSet of given workload
+func isRelatedEvent(event *apiv1.Event, workload string, objects map[string]bool) bool {
+	if objects[event.InvolvedObject.Name] {
+		return true
+	}
+	return event.InvolvedObject.Kind == "ReplicaSet" && isGeneratedChildName(event.InvolvedObject.Name, workload)
+}
+
+//Collect most recent warning events concerning given objects, such as failed probes, mounts or quota issues
+func diagnoseEvents(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, workload string, objects map[string]bool, now time.Time) []*v1.Diagnostic {
+	events, err := kubeAPI.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: "type=" + apiv1.EventTypeWarning})
+	if err != nil {
+		logLine(fmt.Sprintf("Could not retrieve events from namespace %s", namespace))
+		return nil
+	}
+
+	warnings := make([]apiv1.Event, 0)
+	for _, event := range events.Items {
+		if event.Type != apiv1.EventTypeWarning || !isRelatedEvent(&event, workload, objects) {
+			continue
+		}
+		if !eventTime(&event).IsZero() && now.Sub(eventTime(&event)) > recentEventsWindow {
+			continue
+		}
+		warnings = append(warnings, event)
+	}
+
+	//most recent first
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return eventTime(&warnings[i]).After(eventTime(&warnings[j]))
+	})
+	if len(warnings) > maxWarningEvents {
+		warnings = warnings[:maxWarningEvents]
+	}
+
+	diagnostics := make([]*v1.Diagnostic, 0, len(warnings))
+	for _, event := range warnings {
+		diagnostics = append(diagnostics, &v1.Diagnostic{
+			Kind: "Event",
+			Object: event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+			Reason: event.Reason,
+			Message: event.Message,
+			Count: event.Count,
+		})
+	}
+	return diagnostics
+}
+
+//Explain why workload is not ready by inspecting its pods and recent warning events
+func collectReadinessDiagnostics(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, workload string, selector *metav1.LabelSelector) []*v1.Diagnostic {
+	diagnostics := make([]*v1.Diagnostic, 0)
+
+	pods, err := findWorkloadPods(ctx, kubeAPI, namespace, selector)
+	if err != nil {
+		logLine(fmt.Sprintf("Could not retrieve pods of %s: %v", workload, err))
+	}
+
+	objects := map[string]bool{workload: true}
+	for i := range pods {
+		objects[pods[i].Name] = true
+		diagnostics = append(diagnostics, diagnosePod(&pods[i])...)
+	}
+
+	return append(diagnostics, diagnoseEvents(ctx, kubeAPI, namespace, workload, objects, time.Now())...)
+}
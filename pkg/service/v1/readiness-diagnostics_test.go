@@ -0,0 +1,126 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func findDiagnostic(diagnostics []*v1.Diagnostic, reason string) *v1.Diagnostic {
+	for _, d := range diagnostics {
+		if d.Reason == reason {
+			return d
+		}
+	}
+	return nil
+}
+
+func createTestPod(client *testclient.Clientset, name string, status corev1.PodStatus) {
+	pod := corev1.Pod{}
+	pod.Name = name
+	pod.Labels = map[string]string{"app": "test-uid"}
+	pod.Status = status
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &pod, metav1.CreateOptions{})
+}
+
+func createTestWarningEvent(client *testclient.Clientset, name string, kind string, object string, reason string, timestamp time.Time) {
+	event := corev1.Event{}
+	event.Name = name
+	event.Type = corev1.EventTypeWarning
+	event.InvolvedObject = corev1.ObjectReference{Kind: kind, Name: object, Namespace: "test-namespace"}
+	event.Reason = reason
+	event.Count = 3
+	event.LastTimestamp = metav1.NewTime(timestamp)
+	_, _ = client.CoreV1().Events("test-namespace").Create(context.Background(), &event, metav1.CreateOptions{})
+}
+
+func TestReadinessServiceServer_CheckIfReadyWithDiagnostics(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewReadinessServiceServer(client)
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//create mock deployment that is partially deployed
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	q := int32(3)
+	depl.Spec.Replicas = &q
+	depl.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test-uid"}}
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+
+	createTestPod(client, "test-uid-crashing", corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+		Name: "app",
+		RestartCount: 4,
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off restarting failed container"}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+	}}})
+	createTestPod(client, "test-uid-pulling", corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{{
+		Name: "init",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+	}}})
+	createTestPod(client, "test-uid-pending", corev1.PodStatus{Conditions: []corev1.PodCondition{{
+		Type: corev1.PodScheduled,
+		Status: corev1.ConditionFalse,
+		Reason: "Unschedulable",
+		Message: "0/3 nodes are available: 3 Insufficient memory.",
+	}}})
+	createTestPod(client, "test-uid-starting", corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+		Name: "app",
+		State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}}})
+
+	createTestWarningEvent(client, "probe", "Pod", "test-uid-starting", "Unhealthy", time.Now())
+	createTestWarningEvent(client, "quota", "ReplicaSet", "test-uid-5d8f7c", "FailedCreate", time.Now().Add(-time.Minute))
+	createTestWarningEvent(client, "outdated", "Pod", "test-uid-starting", "FailedMount", time.Now().Add(-2*time.Hour))
+	createTestWarningEvent(client, "unrelated", "Pod", "other-pod", "BackOff", time.Now())
+
+	res, err := server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_PENDING {
+		t.Fatal(err)
+	}
+
+	crash := findDiagnostic(res.Diagnostics, "CrashLoopBackOff")
+	if crash == nil || crash.Object != "test-uid-crashing" || crash.Container != "app" || crash.Count != 4 {
+		t.Fail()
+	}
+	if pull := findDiagnostic(res.Diagnostics, "ImagePullBackOff"); pull == nil || pull.Container != "init" {
+		t.Fail()
+	}
+	if pending := findDiagnostic(res.Diagnostics, "Unschedulable"); pending == nil || pending.Object != "test-uid-pending" {
+		t.Fail()
+	}
+	if findDiagnostic(res.Diagnostics, "ContainerNotReady") == nil {
+		t.Fail()
+	}
+
+	//Only recent warning events of the workload are reported, most recent first
+	events := make([]*v1.Diagnostic, 0)
+	for _, d := range res.Diagnostics {
+		if d.Kind == "Event" {
+			events = append(events, d)
+		}
+	}
+	if len(events) != 2 || events[0].Reason != "Unhealthy" || events[1].Object != "ReplicaSet/test-uid-5d8f7c" {
+		t.Fail()
+	}
+
+	//No diagnostics once deployment is ready
+	depl.Status.Replicas = q
+	depl.Status.UpdatedReplicas = q
+	depl.Status.AvailableReplicas = q
+	depl.Status.ReadyReplicas = q
+	_, _ = client.AppsV1().Deployments("test-namespace").Update(context.Background(), &depl, metav1.UpdateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK || len(res.Diagnostics) != 0 {
+		t.Fail()
+	}
+}
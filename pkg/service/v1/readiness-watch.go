@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	defaultReadinessWatchTimeout = 15 * time.Minute
+)
+
+//Readiness is re-evaluated periodically as well, in case a watch misses or drops changes
+var readinessResyncInterval = 30 * time.Second
+
+type watchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+
+//Keep watching resources with given function and signal every change on trigger channel
+func runChangeWatch(ctx context.Context, start watchFunc, trigger chan<- struct{}) {
+	for {
+		w, err := start(ctx, metav1.ListOptions{})
+		if err != nil {
+			logLine(fmt.Sprintf("Could not start watch: %v", err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(readinessResyncInterval):
+				continue
+			}
+		}
+		for range w.ResultChan() {
+			select {
+			case trigger <- struct{}{}:
+			default:
+			}
+		}
+		w.Stop()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+//Watch instance namespace for changes of workloads and pods, signalling them on returned channel
+func watchInstanceChanges(ctx context.Context, kubeAPI kubernetes.Interface, namespace string) <-chan struct{} {
+	trigger := make(chan struct{}, 1)
+	watches := []watchFunc{
+		kubeAPI.AppsV1().Deployments(namespace).Watch,
+		kubeAPI.AppsV1().StatefulSets(namespace).Watch,
+		kubeAPI.AppsV1().DaemonSets(namespace).Watch,
+		kubeAPI.BatchV1().Jobs(namespace).Watch,
+		kubeAPI.BatchV1().CronJobs(namespace).Watch,
+		kubeAPI.CoreV1().Pods(namespace).Watch,
+	}
+	for _, start := range watches {
+		go runChangeWatch(ctx, start, trigger)
+	}
+	return trigger
+}
+
+//Evaluate readiness treating missing workloads as not yet created
+func evaluateWatchedReadiness(ctx context.Context, kubeAPI kubernetes.Interface, depl *v1.Instance) (*v1.ReadinessResponse, error) {
+	res, err := evaluateInstanceReadiness(ctx, kubeAPI, depl)
+	if err != nil && status.Code(err) == codes.NotFound {
+		return prepareReadinessResponse(v1.Status_PENDING, "Waiting for workloads to be created", nil), nil
+	}
+	return res, err
+}
+
+//Stream of readiness responses shared by all RPCs reporting readiness progress
+type readinessStream interface {
+	Send(*v1.ReadinessResponse) error
+	Context() context.Context
+}
+
+//Readiness watch context ends after requested timeout or the default one
+func readinessWatchContext(stream readinessStream, timeoutSeconds int32) (context.Context, context.CancelFunc) {
+	timeout := defaultReadinessWatchTimeout
+	if timeoutSeconds > 0 {
+		timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	return context.WithTimeout(stream.Context(), timeout)
+}
+
+//Send instance readiness whenever it changes until instance is no longer pending or context ends
+func followReadiness(ctx context.Context, kubeAPI kubernetes.Interface, depl *v1.Instance, stream readinessStream, changes <-chan struct{}) error {
+	ticker := time.NewTicker(readinessResyncInterval)
+	defer ticker.Stop()
+
+	var last *v1.ReadinessResponse
+	for {
+		res, err := evaluateWatchedReadiness(ctx, kubeAPI, depl)
+		if err != nil {
+			if ctx.Err() == nil {
+				_ = stream.Send(res)
+				return err
+			}
+		} else if !proto.Equal(res, last) {
+			last = res
+			err = stream.Send(res)
+			if err != nil {
+				return err
+			}
+			if res.Status != v1.Status_PENDING {
+				logLine(fmt.Sprintf("< Instance %s finished with status %s", depl.Uid, res.Status))
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if stream.Context().Err() != nil {
+				return stream.Context().Err()
+			}
+			logLine(fmt.Sprintf("< Timed out waiting for instance %s to become ready", depl.Uid))
+			return stream.Send(prepareReadinessResponse(v1.Status_PENDING, "Timed out waiting for instance readiness", last.GetDiagnostics()))
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *readinessServiceServer) WatchReadiness(req *v1.ReadinessWatchRequest, stream v1.ReadinessService_WatchReadinessServer) error {
+	logLine("> Entered WatchReadiness method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	depl := req.Instance
+	ctx, cancel := readinessWatchContext(stream, req.TimeoutSeconds)
+	defer cancel()
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, namespaceNotFound, nil))
+		return err
+	}
+
+	return followReadiness(ctx, s.kubeAPI, depl, stream, watchInstanceChanges(ctx, s.kubeAPI, depl.Namespace))
+}
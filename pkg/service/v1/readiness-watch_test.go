@@ -0,0 +1,113 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestReadinessServiceServer_WatchReadiness(t *testing.T) {
+	previousInterval := readinessResyncInterval
+	t.Cleanup(func() { readinessResyncInterval = previousInterval })
+	readinessResyncInterval = 50 * time.Millisecond
+
+	client := testclient.NewSimpleClientset()
+	server := NewReadinessServiceServer(client)
+
+	//Fail on API version check
+	illreq := v1.ReadinessWatchRequest{Api: "dummy", Instance: &inst}
+	err := server.WatchReadiness(&illreq, newFakeServerStream[v1.ReadinessResponse]())
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	watchReq := v1.ReadinessWatchRequest{Api: apiVersion, Instance: &inst, TimeoutSeconds: 10}
+	stream := newFakeServerStream[v1.ReadinessResponse]()
+	err = server.WatchReadiness(&watchReq, stream)
+	if err == nil || (<-stream.responses).Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	stream = newFakeServerStream[v1.ReadinessResponse]()
+	done := make(chan error)
+	go func() {
+		done <- server.WatchReadiness(&watchReq, stream)
+	}()
+
+	//Waiting for workloads to be created
+	res := <-stream.responses
+	if res.Status != v1.Status_PENDING || res.Message != "Waiting for workloads to be created" {
+		t.Fail()
+	}
+
+	//create mock deployment that is partially deployed
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	q := int32(2)
+	depl.Spec.Replicas = &q
+	depl.Status.Replicas = q
+	depl.Status.UpdatedReplicas = q
+	depl.Status.AvailableReplicas = 1
+	depl.Status.ReadyReplicas = 1
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+
+	res = <-stream.responses
+	if res.Status != v1.Status_PENDING || res.Message != "Rolling out: 1/2 updated replicas available" || res.Workloads[0].Ready != 1 {
+		t.Fail()
+	}
+
+	//Pass once deployment is ready
+	depl.Status.AvailableReplicas = q
+	depl.Status.ReadyReplicas = q
+	_, _ = client.AppsV1().Deployments("test-namespace").Update(context.Background(), &depl, metav1.UpdateOptions{})
+
+	res = <-stream.responses
+	if res.Status != v1.Status_OK {
+		t.Fail()
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadinessServiceServer_WatchReadinessTimeout(t *testing.T) {
+	previousInterval := readinessResyncInterval
+	t.Cleanup(func() { readinessResyncInterval = previousInterval })
+	readinessResyncInterval = 50 * time.Millisecond
+
+	client := testclient.NewSimpleClientset()
+	server := NewReadinessServiceServer(client)
+
+	//create mock namespace and deployment that never becomes ready
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+
+	stream := newFakeServerStream[v1.ReadinessResponse]()
+	err := server.WatchReadiness(&v1.ReadinessWatchRequest{Api: apiVersion, Instance: &inst, TimeoutSeconds: 1}, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last *v1.ReadinessResponse
+	for len(stream.responses) > 0 {
+		last = <-stream.responses
+	}
+	if last == nil || last.Status != v1.Status_PENDING || last.Message != "Timed out waiting for instance readiness" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+//Prepare service endpoints response
+func prepareServiceEndpointsResponse(status v1.Status, message string, services []*v1.ServiceEndpoints) *v1.ServiceEndpointsResponse {
+	return &v1.ServiceEndpointsResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+		Services: services,
+	}
+}
+
+//Retrieve service named after the instance together with all services labelled with it
+func findInstanceServices(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, uid string) ([]apiv1.Service, error) {
+	services := make([]apiv1.Service, 0)
+	named, err := kubeAPI.CoreV1().Services(namespace).Get(ctx, uid, metav1.GetOptions{})
+	if err == nil {
+		services = append(services, *named)
+	}
+
+	labelled, err := kubeAPI.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", instanceLabel, uid)})
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range labelled.Items {
+		if service.Name != uid {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+//Describe every address and port under which given service is reachable
+func describeServiceEndpoints(service *apiv1.Service) *v1.ServiceEndpoints {
+	endpoints := &v1.ServiceEndpoints{
+		Name: service.Name,
+		Type: string(service.Spec.Type),
+		ClusterIp: service.Spec.ClusterIP,
+		ExternalIps: service.Spec.ExternalIPs,
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			endpoints.LoadBalancerIps = append(endpoints.LoadBalancerIps, ingress.IP)
+		}
+		if ingress.Hostname != "" {
+			endpoints.LoadBalancerHostnames = append(endpoints.LoadBalancerHostnames, ingress.Hostname)
+		}
+	}
+	for _, port := range service.Spec.Ports {
+		endpoints.Ports = append(endpoints.Ports, &v1.ServicePort{
+			Name: port.Name,
+			Port: port.Port,
+			Protocol: string(port.Protocol),
+			NodePort: port.NodePort,
+			TargetPort: port.TargetPort.String(),
+		})
+	}
+	return endpoints
+}
+
+func (s *informationServiceServer) RetrieveServiceEndpoints(ctx context.Context, req *v1.InstanceRequest) (*v1.ServiceEndpointsResponse, error) {
+	logLine("> Entered RetrieveServiceEndpoints method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Deployment
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareServiceEndpointsResponse(v1.Status_FAILED, namespaceNotFound, nil), err
+	}
+
+	services, err := findInstanceServices(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return prepareServiceEndpointsResponse(v1.Status_FAILED, "Error while retrieving services!", nil), err
+	}
+	if len(services) == 0 {
+		logLine("Service not found")
+		return prepareServiceEndpointsResponse(v1.Status_FAILED, "Service not found!", nil), status.Errorf(codes.NotFound, "no services found for instance %s in namespace %s", depl.Uid, depl.Namespace)
+	}
+
+	endpoints := make([]*v1.ServiceEndpoints, 0, len(services))
+	for i := range services {
+		endpoints = append(endpoints, describeServiceEndpoints(&services[i]))
+	}
+
+	logLine(fmt.Sprintf("< Found %d service(s)", len(endpoints)))
+	return prepareServiceEndpointsResponse(v1.Status_OK, "", endpoints), nil
+}
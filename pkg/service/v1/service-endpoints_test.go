@@ -0,0 +1,81 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestInformationServiceServer_RetrieveServiceEndpoints(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewInformationServiceServer(client, newFakeDynamicClient())
+
+	//Fail on API version check
+	res, err := server.RetrieveServiceEndpoints(context.Background(), &illegal_req)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	freq := v1.InstanceRequest{Api: apiVersion, Deployment: &fake_ns_inst}
+	res, err = server.RetrieveServiceEndpoints(context.Background(), &freq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing service
+	res, err = server.RetrieveServiceEndpoints(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED || res.Message != "Service not found!" {
+		t.Fail()
+	}
+
+	//create mock load balancer service exposing both TCP and UDP ports
+	s1 := corev1.Service{}
+	s1.Name = "test-uid"
+	s1.Spec.Type = corev1.ServiceTypeLoadBalancer
+	s1.Spec.ClusterIP = "10.96.0.10"
+	s1.Spec.ExternalIPs = []string{"192.0.2.10"}
+	s1.Spec.Ports = []corev1.ServicePort{
+		{Name: "syslog-tcp", Port: 514, Protocol: corev1.ProtocolTCP, NodePort: 30514, TargetPort: intstr.FromInt(5140)},
+		{Name: "syslog-udp", Port: 514, Protocol: corev1.ProtocolUDP, NodePort: 30515, TargetPort: intstr.FromString("syslog")},
+	}
+	s1.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.10.1.1"}, {Hostname: "lb.example.com"}}
+	_, _ = client.CoreV1().Services("test-namespace").Create(context.Background(), &s1, metav1.CreateOptions{})
+
+	//create mock service labelled with the instance
+	s2 := corev1.Service{}
+	s2.Name = "test-uid-snmp"
+	s2.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	s2.Spec.Type = corev1.ServiceTypeClusterIP
+	s2.Spec.Ports = []corev1.ServicePort{{Port: 161, Protocol: corev1.ProtocolUDP}}
+	_, _ = client.CoreV1().Services("test-namespace").Create(context.Background(), &s2, metav1.CreateOptions{})
+
+	//Pass
+	res, err = server.RetrieveServiceEndpoints(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK || len(res.Services) != 2 {
+		t.Fatal(err)
+	}
+
+	lb := res.Services[0]
+	if lb.Name != "test-uid" || lb.Type != "LoadBalancer" || lb.ClusterIp != "10.96.0.10" || lb.ExternalIps[0] != "192.0.2.10" {
+		t.Fail()
+	}
+	if len(lb.LoadBalancerIps) != 1 || lb.LoadBalancerIps[0] != "10.10.1.1" || len(lb.LoadBalancerHostnames) != 1 || lb.LoadBalancerHostnames[0] != "lb.example.com" {
+		t.Fail()
+	}
+	if len(lb.Ports) != 2 || lb.Ports[1].Protocol != "UDP" || lb.Ports[1].NodePort != 30515 || lb.Ports[0].TargetPort != "5140" || lb.Ports[1].TargetPort != "syslog" {
+		t.Fail()
+	}
+	if res.Services[1].Name != "test-uid-snmp" || res.Services[1].Ports[0].Port != 161 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	defaultProbeTimeout = 5 * time.Second
+)
+
+//Prepare service probe response
+func prepareServiceProbeResponse(status v1.Status, message string) *v1.ServiceProbeResponse {
+	return &v1.ServiceProbeResponse {
+		Api: apiVersion,
+		Status: status,
+		Message: message,
+	}
+}
+
+//Count ready and not ready endpoints across service endpoint slices, unset ready condition means ready
+func countEndpoints(slices []discoveryv1.EndpointSlice) (int32, int32) {
+	ready, notReady := int32(0), int32(0)
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready++
+			} else {
+				notReady++
+			}
+		}
+	}
+	return ready, notReady
+}
+
+//Select service port to probe, first one if none was requested
+func selectServicePort(service *apiv1.Service, port int32) (*apiv1.ServicePort, error) {
+	if len(service.Spec.Ports) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "service %s does not expose any ports", service.Name)
+	}
+	if port == 0 {
+		return &service.Spec.Ports[0], nil
+	}
+	for i := range service.Spec.Ports {
+		if service.Spec.Ports[i].Port == port {
+			return &service.Spec.Ports[i], nil
+		}
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "service %s does not expose port %d", service.Name, port)
+}
+
+//Check that port can be probed by connecting to it, only TCP ports accept connections and HTTP requests
+func checkProbePortProtocol(port *apiv1.ServicePort, probeType v1.ProbeType) error {
+	if probeType == v1.ProbeType_ENDPOINTS_ONLY || len(port.Protocol) == 0 || port.Protocol == apiv1.ProtocolTCP {
+		return nil
+	}
+	return status.Errorf(codes.InvalidArgument, "%s probe cannot be run against %s port %d", probeType, port.Protocol, port.Port)
+}
+
+//Address under which service is reachable from inside the cluster, headless services are resolved through cluster DNS
+func serviceProbeAddress(service *apiv1.Service, port int32) string {
+	host := service.Spec.ClusterIP
+	if len(host) == 0 || host == apiv1.ClusterIPNone {
+		host = fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+//Open TCP connection to given address
+func probeTcp(ctx context.Context, address string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+//Send HTTP GET request to given address and return response status code, redirects are not followed
+func probeHttp(ctx context.Context, address string, path string) (int, error) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://" + address + path, nil)
+	if err != nil {
+		return 0, err
+	}
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	return response.StatusCode, nil
+}
+
+func (s *informationServiceServer) ProbeService(ctx context.Context, req *v1.ServiceProbeRequest) (*v1.ServiceProbeResponse, error) {
+	logLine("> Entered ProbeService method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return nil, err
+	}
+
+	depl := req.Instance
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return prepareServiceProbeResponse(v1.Status_FAILED, namespaceNotFound), err
+	}
+
+	name := req.ServiceName
+	if len(name) == 0 {
+		name = depl.Uid
+	}
+	service, err := s.kubeAPI.CoreV1().Services(depl.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return prepareServiceProbeResponse(v1.Status_FAILED, "Service not found!"), err
+	}
+
+	port, err := selectServicePort(service, req.Port)
+	if err != nil {
+		return prepareServiceProbeResponse(v1.Status_FAILED, "Service port not found!"), err
+	}
+	if err = checkProbePortProtocol(port, req.Type); err != nil {
+		return prepareServiceProbeResponse(v1.Status_FAILED, "Service port does not use TCP!"), err
+	}
+
+	slices, err := s.kubeAPI.DiscoveryV1().EndpointSlices(depl.Namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, name)})
+	if err != nil {
+		return prepareServiceProbeResponse(v1.Status_FAILED, "Error while retrieving endpoints!"), err
+	}
+
+	res := prepareServiceProbeResponse(v1.Status_OK, "Service has ready endpoints")
+	res.ReadyEndpoints, res.NotReadyEndpoints = countEndpoints(slices.Items)
+	if res.ReadyEndpoints == 0 {
+		res.Status, res.Message = v1.Status_FAILED, "Service has no ready endpoints"
+		return res, nil
+	}
+	if req.Type == v1.ProbeType_ENDPOINTS_ONLY {
+		return res, nil
+	}
+
+	timeout := defaultProbeTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	address := serviceProbeAddress(service, port.Port)
+	logLine(fmt.Sprintf("Probing %s with %s", address, req.Type))
+	start := time.Now()
+	if req.Type == v1.ProbeType_HTTP {
+		code, err := probeHttp(probeCtx, address, req.HttpPath)
+		res.LatencyMillis = time.Since(start).Milliseconds()
+		res.HttpStatusCode = int32(code)
+		if err != nil {
+			res.Status, res.Message = v1.Status_FAILED, fmt.Sprintf("HTTP request failed: %v", err)
+		} else if code >= http.StatusBadRequest {
+			res.Status, res.Message = v1.Status_FAILED, fmt.Sprintf("Service answered with HTTP status %d", code)
+		} else {
+			res.Message = fmt.Sprintf("Service answered with HTTP status %d", code)
+		}
+	} else {
+		err = probeTcp(probeCtx, address)
+		res.LatencyMillis = time.Since(start).Milliseconds()
+		if err != nil {
+			res.Status, res.Message = v1.Status_FAILED, fmt.Sprintf("TCP connection failed: %v", err)
+		} else {
+			res.Message = "Service accepted TCP connection"
+		}
+	}
+
+	logLine(fmt.Sprintf("< Probe of %s finished with status %s in %dms", address, res.Status, res.LatencyMillis))
+	return res, nil
+}
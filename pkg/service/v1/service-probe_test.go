@@ -0,0 +1,134 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestInformationServiceServer_ProbeService(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewInformationServiceServer(client, newFakeDynamicClient())
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer backend.Close()
+	_, portStr, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	//Fail on API version check
+	illreq := v1.ServiceProbeRequest{Api: "dummy", Instance: &inst}
+	res, err := server.ProbeService(context.Background(), &illreq)
+	if err == nil || res != nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	probeReq := v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst}
+	res, err = server.ProbeService(context.Background(), &probeReq)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing service
+	res, err = server.ProbeService(context.Background(), &probeReq)
+	if err == nil || res.Status != v1.Status_FAILED || res.Message != "Service not found!" {
+		t.Fail()
+	}
+
+	//create mock service pointing at local test server
+	svc := corev1.Service{}
+	svc.Name = "test-uid"
+	svc.Spec.ClusterIP = "127.0.0.1"
+	svc.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: int32(port), Protocol: corev1.ProtocolTCP}, {Name: "dns", Port: 53, Protocol: corev1.ProtocolUDP}}
+	_, _ = client.CoreV1().Services("test-namespace").Create(context.Background(), &svc, metav1.CreateOptions{})
+
+	//Fail on port not exposed by service
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Port: 1})
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Fail on missing ready endpoints
+	res, err = server.ProbeService(context.Background(), &probeReq)
+	if err != nil || res.Status != v1.Status_FAILED || res.ReadyEndpoints != 0 {
+		t.Fail()
+	}
+
+	//create mock endpoint slice with one ready and one not ready endpoint
+	ready, notReady := true, false
+	slice := discoveryv1.EndpointSlice{}
+	slice.Name = "test-uid-abcde"
+	slice.Labels = map[string]string{discoveryv1.LabelServiceName: "test-uid"}
+	slice.AddressType = discoveryv1.AddressTypeIPv4
+	slice.Endpoints = []discoveryv1.Endpoint{
+		{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+		{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+	}
+	_, _ = client.DiscoveryV1().EndpointSlices("test-namespace").Create(context.Background(), &slice, metav1.CreateOptions{})
+
+	//Pass on endpoints only
+	res, err = server.ProbeService(context.Background(), &probeReq)
+	if err != nil || res.Status != v1.Status_OK || res.ReadyEndpoints != 1 || res.NotReadyEndpoints != 1 {
+		t.Fail()
+	}
+
+	//Pass on TCP connection
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Type: v1.ProbeType_TCP})
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	//Pass on HTTP request
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Type: v1.ProbeType_HTTP, HttpPath: "/health"})
+	if err != nil || res.Status != v1.Status_OK || res.HttpStatusCode != http.StatusOK {
+		t.Fail()
+	}
+
+	//Fail on TCP and HTTP probes of UDP port
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Type: v1.ProbeType_TCP, Port: 53})
+	if status.Code(err) != codes.InvalidArgument || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Type: v1.ProbeType_HTTP, Port: 53})
+	if status.Code(err) != codes.InvalidArgument || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Pass on endpoints only check of UDP port
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Port: 53})
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	//Fail on HTTP error status
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Type: v1.ProbeType_HTTP, HttpPath: "missing"})
+	if err != nil || res.Status != v1.Status_FAILED || res.HttpStatusCode != http.StatusNotFound {
+		t.Fail()
+	}
+
+	//Fail on TCP connection to closed port
+	backend.Close()
+	res, err = server.ProbeService(context.Background(), &v1.ServiceProbeRequest{Api: apiVersion, Instance: &inst, Type: v1.ProbeType_TCP})
+	if err != nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+}
@@ -0,0 +1,282 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+//Workload belonging to an instance together with its evaluated readiness
+type instanceWorkload struct {
+	status *v1.WorkloadStatus
+	selector *metav1.LabelSelector
+}
+
+//Prepare workload status
+func prepareWorkloadStatus(kind string, name string, status v1.Status, message string, ready int32, desired int32) *v1.WorkloadStatus {
+	return &v1.WorkloadStatus {
+		Kind: kind,
+		Name: name,
+		Status: status,
+		Message: message,
+		Ready: ready,
+		Desired: desired,
+	}
+}
+
+//Kubernetes defaults unset replica count to one
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+//Evaluate deployment the same way kubectl rollout status does, so that instance is not reported ready in the middle of a rollout
+func deploymentReadiness(dep *appsv1.Deployment) *v1.WorkloadStatus {
+	desired := replicasOrDefault(dep.Spec.Replicas)
+	st := dep.Status
+	pending := func(message string) *v1.WorkloadStatus {
+		return prepareWorkloadStatus("Deployment", dep.Name, v1.Status_PENDING, message, st.ReadyReplicas, desired)
+	}
+
+	if dep.Generation > st.ObservedGeneration {
+		return pending("Waiting for deployment spec update to be observed")
+	}
+	for _, condition := range st.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return prepareWorkloadStatus("Deployment", dep.Name, v1.Status_FAILED, fmt.Sprintf("Deployment exceeded its progress deadline: %s", condition.Message), st.ReadyReplicas, desired)
+		}
+	}
+	if st.UpdatedReplicas < desired {
+		return pending(fmt.Sprintf("Rolling out: %d/%d replicas updated", st.UpdatedReplicas, desired))
+	}
+	if st.Replicas > st.UpdatedReplicas {
+		return pending(fmt.Sprintf("Rolling out: %d old replicas pending termination", st.Replicas - st.UpdatedReplicas))
+	}
+	if st.AvailableReplicas < st.UpdatedReplicas {
+		return pending(fmt.Sprintf("Rolling out: %d/%d updated replicas available", st.AvailableReplicas, st.UpdatedReplicas))
+	}
+	if st.ReadyReplicas < desired {
+		return pending(fmt.Sprintf("Waiting for deployment: %d/%d replicas ready", st.ReadyReplicas, desired))
+	}
+	return prepareWorkloadStatus("Deployment", dep.Name, v1.Status_OK, "Deployment is ready", st.ReadyReplicas, desired)
+}
+
+func statefulSetReadiness(sts *appsv1.StatefulSet) *v1.WorkloadStatus {
+	desired := replicasOrDefault(sts.Spec.Replicas)
+	st := sts.Status
+	pending := func(message string) *v1.WorkloadStatus {
+		return prepareWorkloadStatus("StatefulSet", sts.Name, v1.Status_PENDING, message, st.ReadyReplicas, desired)
+	}
+
+	if sts.Generation > st.ObservedGeneration {
+		return pending("Waiting for statefulset spec update to be observed")
+	}
+	if st.ReadyReplicas < desired {
+		return pending(fmt.Sprintf("Waiting for statefulset: %d/%d replicas ready", st.ReadyReplicas, desired))
+	}
+	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		//pods are replaced only when deleted manually, so there is no rollout to wait for
+		return prepareWorkloadStatus("StatefulSet", sts.Name, v1.Status_OK, "StatefulSet is ready", st.ReadyReplicas, desired)
+	}
+	if rollingUpdate := sts.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		if expected := desired - *rollingUpdate.Partition; st.UpdatedReplicas < expected {
+			return pending(fmt.Sprintf("Rolling out: %d/%d replicas updated", st.UpdatedReplicas, expected))
+		}
+		return prepareWorkloadStatus("StatefulSet", sts.Name, v1.Status_OK, "StatefulSet partitioned rollout is complete", st.ReadyReplicas, desired)
+	}
+	if st.UpdateRevision != st.CurrentRevision {
+		return pending(fmt.Sprintf("Rolling out: %d/%d replicas updated", st.UpdatedReplicas, desired))
+	}
+	return prepareWorkloadStatus("StatefulSet", sts.Name, v1.Status_OK, "StatefulSet is ready", st.ReadyReplicas, desired)
+}
+
+//DaemonSet is ready once updated pods are available on every node it is scheduled to
+func daemonSetReadiness(ds *appsv1.DaemonSet) *v1.WorkloadStatus {
+	st := ds.Status
+	desired := st.DesiredNumberScheduled
+	pending := func(message string) *v1.WorkloadStatus {
+		return prepareWorkloadStatus("DaemonSet", ds.Name, v1.Status_PENDING, message, st.NumberReady, desired)
+	}
+
+	if ds.Generation > st.ObservedGeneration {
+		return pending("Waiting for daemonset spec update to be observed")
+	}
+	if ds.Spec.UpdateStrategy.Type != appsv1.OnDeleteDaemonSetStrategyType {
+		if st.UpdatedNumberScheduled < desired {
+			return pending(fmt.Sprintf("Rolling out: %d/%d pods updated", st.UpdatedNumberScheduled, desired))
+		}
+		if st.NumberAvailable < desired {
+			return pending(fmt.Sprintf("Rolling out: %d/%d updated pods available", st.NumberAvailable, desired))
+		}
+	}
+	if st.NumberReady < desired {
+		return pending(fmt.Sprintf("Waiting for daemonset: %d/%d pods ready", st.NumberReady, desired))
+	}
+	return prepareWorkloadStatus("DaemonSet", ds.Name, v1.Status_OK, "DaemonSet is ready", st.NumberReady, desired)
+}
+
+//Job is ready once it completed, a failed job will not recover on its own
+func jobReadiness(job *batchv1.Job) *v1.WorkloadStatus {
+	desired := replicasOrDefault(job.Spec.Completions)
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != apiv1.ConditionTrue {
+			continue
+		}
+		if condition.Type == batchv1.JobFailed {
+			return prepareWorkloadStatus("Job", job.Name, v1.Status_FAILED, fmt.Sprintf("Job failed: %s", condition.Message), job.Status.Succeeded, desired)
+		}
+		if condition.Type == batchv1.JobComplete {
+			return prepareWorkloadStatus("Job", job.Name, v1.Status_OK, "Job completed", job.Status.Succeeded, desired)
+		}
+	}
+	if job.Status.Succeeded >= desired {
+		return prepareWorkloadStatus("Job", job.Name, v1.Status_OK, "Job completed", job.Status.Succeeded, desired)
+	}
+	return prepareWorkloadStatus("Job", job.Name, v1.Status_PENDING, "Waiting for job to complete", job.Status.Succeeded, desired)
+}
+
+//CronJob has nothing to wait for, its runs are not part of instance readiness
+func cronJobReadiness(cronJob *batchv1.CronJob) *v1.WorkloadStatus {
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return prepareWorkloadStatus("CronJob", cronJob.Name, v1.Status_OK, "CronJob is suspended", 0, 0)
+	}
+	return prepareWorkloadStatus("CronJob", cronJob.Name, v1.Status_OK, "CronJob is scheduled", 0, 0)
+}
+
+//Check if job was spawned by a cronjob
+func isOwnedByCronJob(job *batchv1.Job) bool {
+	for _, owner := range job.OwnerReferences {
+		if owner.Kind == "CronJob" {
+			return true
+		}
+	}
+	return false
+}
+
+//Retrieve all workloads labelled with the instance, as well as deployment or statefulset named after it
+func findInstanceWorkloads(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, uid string) ([]instanceWorkload, error) {
+	workloads := make([]instanceWorkload, 0)
+	options := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", instanceLabel, uid)}
+
+	deployments, err := kubeAPI.AppsV1().Deployments(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	namedDeployment := false
+	for i := range deployments.Items {
+		dep := &deployments.Items[i]
+		namedDeployment = namedDeployment || dep.Name == uid
+		workloads = append(workloads, instanceWorkload{deploymentReadiness(dep), dep.Spec.Selector})
+	}
+	if !namedDeployment {
+		dep, err := kubeAPI.AppsV1().Deployments(namespace).Get(ctx, uid, metav1.GetOptions{})
+		if err == nil {
+			workloads = append(workloads, instanceWorkload{deploymentReadiness(dep), dep.Spec.Selector})
+		}
+	}
+
+	statefulSets, err := kubeAPI.AppsV1().StatefulSets(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	namedStatefulSet := false
+	for i := range statefulSets.Items {
+		sts := &statefulSets.Items[i]
+		namedStatefulSet = namedStatefulSet || sts.Name == uid
+		workloads = append(workloads, instanceWorkload{statefulSetReadiness(sts), sts.Spec.Selector})
+	}
+	if !namedStatefulSet {
+		sts, err := kubeAPI.AppsV1().StatefulSets(namespace).Get(ctx, uid, metav1.GetOptions{})
+		if err == nil {
+			workloads = append(workloads, instanceWorkload{statefulSetReadiness(sts), sts.Spec.Selector})
+		}
+	}
+
+	daemonSets, err := kubeAPI.AppsV1().DaemonSets(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		ds := &daemonSets.Items[i]
+		workloads = append(workloads, instanceWorkload{daemonSetReadiness(ds), ds.Spec.Selector})
+	}
+
+	jobs, err := kubeAPI.BatchV1().Jobs(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if isOwnedByCronJob(job) {
+			continue
+		}
+		workloads = append(workloads, instanceWorkload{jobReadiness(job), job.Spec.Selector})
+	}
+
+	cronJobs, err := kubeAPI.BatchV1().CronJobs(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cronJobs.Items {
+		workloads = append(workloads, instanceWorkload{cronJobReadiness(&cronJobs.Items[i]), nil})
+	}
+
+	return workloads, nil
+}
+
+//Evaluate readiness of all instance workloads and aggregate it into a single status
+func evaluateInstanceReadiness(ctx context.Context, kubeAPI kubernetes.Interface, depl *v1.Instance) (*v1.ReadinessResponse, error) {
+	workloads, err := findInstanceWorkloads(ctx, kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		return prepareReadinessResponse(v1.Status_FAILED, "Error while retrieving workloads!", nil), err
+	}
+	if len(workloads) == 0 {
+		logLine("no workloads found")
+		return prepareReadinessResponse(v1.Status_FAILED, "No workloads found for instance!", nil), status.Errorf(codes.NotFound, "no workloads found for instance %s in namespace %s", depl.Uid, depl.Namespace)
+	}
+
+	statuses := make([]*v1.WorkloadStatus, 0, len(workloads))
+	diagnostics := make([]*v1.Diagnostic, 0)
+	failed := make([]string, 0)
+	pending := make([]string, 0)
+	for _, workload := range workloads {
+		ws := workload.status
+		statuses = append(statuses, ws)
+		switch ws.Status {
+		case v1.Status_FAILED:
+			failed = append(failed, ws.Kind + "/" + ws.Name)
+		case v1.Status_PENDING:
+			pending = append(pending, ws.Kind + "/" + ws.Name)
+		default:
+			continue
+		}
+		diagnostics = append(diagnostics, collectReadinessDiagnostics(ctx, kubeAPI, depl.Namespace, ws.Name, workload.selector)...)
+	}
+
+	var res *v1.ReadinessResponse
+	switch {
+	case len(workloads) == 1:
+		res = prepareReadinessResponse(statuses[0].Status, statuses[0].Message, diagnostics)
+	case len(failed) > 0:
+		res = prepareReadinessResponse(v1.Status_FAILED, fmt.Sprintf("Failed workloads: %s", strings.Join(failed, ", ")), diagnostics)
+	case len(pending) > 0:
+		res = prepareReadinessResponse(v1.Status_PENDING, fmt.Sprintf("Waiting for %s", strings.Join(pending, ", ")), diagnostics)
+	default:
+		res = prepareReadinessResponse(v1.Status_OK, fmt.Sprintf("All %d workloads are ready", len(workloads)), diagnostics)
+	}
+	res.Workloads = statuses
+	logLine(fmt.Sprintf("instance status: %s", res.Status))
+	return res, nil
+}
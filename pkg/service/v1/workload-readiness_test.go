@@ -0,0 +1,211 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+var instanceLabels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+
+func findWorkloadStatus(workloads []*v1.WorkloadStatus, kind string, name string) *v1.WorkloadStatus {
+	for _, w := range workloads {
+		if w.Kind == kind && w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+func TestReadinessServiceServer_CheckIfReadyWithMultipleWorkloads(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewReadinessServiceServer(client)
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on missing workloads
+	res, err := server.CheckIfReady(context.Background(), &req)
+	if err == nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock workloads labelled with the instance
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid-web"
+	depl.Labels = instanceLabels
+	depl.Status.Replicas = 1
+	depl.Status.UpdatedReplicas = 1
+	depl.Status.AvailableReplicas = 1
+	depl.Status.ReadyReplicas = 1
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+
+	sts := appsv1.StatefulSet{}
+	sts.Name = "test-uid-db"
+	sts.Labels = instanceLabels
+	q := int32(2)
+	sts.Spec.Replicas = &q
+	sts.Status.ReadyReplicas = q
+	_, _ = client.AppsV1().StatefulSets("test-namespace").Create(context.Background(), &sts, metav1.CreateOptions{})
+
+	ds := appsv1.DaemonSet{}
+	ds.Name = "test-uid-agent"
+	ds.Labels = instanceLabels
+	ds.Status.DesiredNumberScheduled = 3
+	ds.Status.UpdatedNumberScheduled = 3
+	ds.Status.NumberAvailable = 2
+	ds.Status.NumberReady = 2
+	_, _ = client.AppsV1().DaemonSets("test-namespace").Create(context.Background(), &ds, metav1.CreateOptions{})
+
+	job := batchv1.Job{}
+	job.Name = "test-uid-migrate"
+	job.Labels = instanceLabels
+	_, _ = client.BatchV1().Jobs("test-namespace").Create(context.Background(), &job, metav1.CreateOptions{})
+
+	cronJob := batchv1.CronJob{}
+	cronJob.Name = "test-uid-backup"
+	cronJob.Labels = instanceLabels
+	_, _ = client.BatchV1().CronJobs("test-namespace").Create(context.Background(), &cronJob, metav1.CreateOptions{})
+
+	//failed cronjob runs are not taken into account
+	run := batchv1.Job{}
+	run.Name = "test-uid-backup-28000000"
+	run.Labels = instanceLabels
+	run.OwnerReferences = []metav1.OwnerReference{{Kind: "CronJob", Name: "test-uid-backup"}}
+	run.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+	_, _ = client.BatchV1().Jobs("test-namespace").Create(context.Background(), &run, metav1.CreateOptions{})
+
+	//workloads of other instances are ignored
+	other := appsv1.Deployment{}
+	other.Name = "other-uid"
+	other.Labels = map[string]string{"app.kubernetes.io/instance": "other-uid"}
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &other, metav1.CreateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_PENDING || len(res.Workloads) != 5 {
+		t.Fatal(err)
+	}
+	if res.Message != "Waiting for DaemonSet/test-uid-agent, Job/test-uid-migrate" {
+		t.Fail()
+	}
+	if w := findWorkloadStatus(res.Workloads, "Deployment", "test-uid-web"); w == nil || w.Status != v1.Status_OK || w.Desired != 1 {
+		t.Fail()
+	}
+	if w := findWorkloadStatus(res.Workloads, "DaemonSet", "test-uid-agent"); w == nil || w.Ready != 2 || w.Desired != 3 {
+		t.Fail()
+	}
+	if w := findWorkloadStatus(res.Workloads, "CronJob", "test-uid-backup"); w == nil || w.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	//Fail on failed job
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+	_, _ = client.BatchV1().Jobs("test-namespace").Update(context.Background(), &job, metav1.UpdateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_FAILED || res.Message != "Failed workloads: Job/test-uid-migrate" {
+		t.Fail()
+	}
+
+	//Pass once every workload is ready
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	job.Status.Succeeded = 1
+	_, _ = client.BatchV1().Jobs("test-namespace").Update(context.Background(), &job, metav1.UpdateOptions{})
+	ds.Status.NumberAvailable = 3
+	ds.Status.NumberReady = 3
+	_, _ = client.AppsV1().DaemonSets("test-namespace").Update(context.Background(), &ds, metav1.UpdateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK || res.Message != "All 5 workloads are ready" {
+		t.Fail()
+	}
+}
+
+func TestReadinessServiceServer_CheckIfReadyDuringRollout(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	server := NewReadinessServiceServer(client)
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//create mock deployment with unset replica count and a spec update not yet observed
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	depl.Generation = 2
+	depl.Status.ObservedGeneration = 1
+	depl.Status.Replicas = 1
+	depl.Status.UpdatedReplicas = 1
+	depl.Status.AvailableReplicas = 1
+	depl.Status.ReadyReplicas = 1
+	_, _ = client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+
+	res, err := server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_PENDING || res.Message != "Waiting for deployment spec update to be observed" {
+		t.Fail()
+	}
+
+	//new replica set created while old pods are still serving
+	q := int32(3)
+	depl.Spec.Replicas = &q
+	depl.Status.ObservedGeneration = 2
+	depl.Status.Replicas = 4
+	depl.Status.UpdatedReplicas = 1
+	depl.Status.ReadyReplicas = 3
+	_, _ = client.AppsV1().Deployments("test-namespace").Update(context.Background(), &depl, metav1.UpdateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_PENDING || res.Message != "Rolling out: 1/3 replicas updated" {
+		t.Fail()
+	}
+
+	//old replicas still terminating
+	depl.Status.UpdatedReplicas = 3
+	depl.Status.AvailableReplicas = 3
+	_, _ = client.AppsV1().Deployments("test-namespace").Update(context.Background(), &depl, metav1.UpdateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_PENDING || res.Message != "Rolling out: 1 old replicas pending termination" {
+		t.Fail()
+	}
+
+	//Fail on exceeded progress deadline
+	depl.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"}}
+	_, _ = client.AppsV1().Deployments("test-namespace").Update(context.Background(), &depl, metav1.UpdateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//Pass once rollout is complete
+	depl.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "NewReplicaSetAvailable"}}
+	depl.Status.Replicas = 3
+	_, _ = client.AppsV1().Deployments("test-namespace").Update(context.Background(), &depl, metav1.UpdateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_OK {
+		t.Fail()
+	}
+
+	//statefulset with pods at an old revision
+	sts := appsv1.StatefulSet{}
+	sts.Name = "test-uid"
+	sts.Status.ReadyReplicas = 1
+	sts.Status.CurrentRevision = "test-uid-1"
+	sts.Status.UpdateRevision = "test-uid-2"
+	_, _ = client.AppsV1().StatefulSets("test-namespace").Create(context.Background(), &sts, metav1.CreateOptions{})
+
+	res, err = server.CheckIfReady(context.Background(), &req)
+	if err != nil || res.Status != v1.Status_PENDING || res.Message != "Waiting for StatefulSet/test-uid" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+)
+
+const (
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+//Patch pod template annotation the same way kubectl rollout restart does, which makes controller replace all pods
+func restartWorkload(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, kind string, name string, now time.Time) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"%s":"%s"}}}}}`, restartedAtAnnotation, now.Format(time.RFC3339)))
+	var err error
+	switch kind {
+	case "Deployment":
+		_, err = kubeAPI.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = kubeAPI.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	default:
+		return status.Errorf(codes.InvalidArgument, "%s %s cannot be restarted", kind, name)
+	}
+	return err
+}
+
+//Wait until deleted pod is gone or replaced by a pod with the same name, as statefulset pods are
+func waitForPodReplacement(ctx context.Context, kubeAPI kubernetes.Interface, namespace string, name string, uid types.UID, changes <-chan struct{}) error {
+	ticker := time.NewTicker(readinessResyncInterval)
+	defer ticker.Stop()
+	for {
+		pod, err := kubeAPI.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || (err == nil && pod.UID != uid) {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *readinessServiceServer) RestartPod(req *v1.PodRestartRequest, stream v1.ReadinessService_RestartPodServer) error {
+	logLine("> Entered RestartPod method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	depl := req.Instance
+	ctx, cancel := readinessWatchContext(stream, req.TimeoutSeconds)
+	defer cancel()
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, namespaceNotFound, nil))
+		return err
+	}
+
+	pod, err := findInstancePod(ctx, s.kubeAPI, depl, req.Pod)
+	if err != nil {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, "Pod not found", nil))
+		return err
+	}
+
+	//pod without controller would not come back after deletion
+	if metav1.GetControllerOf(pod) == nil {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, "Pod is not managed by a controller", nil))
+		return status.Errorf(codes.FailedPrecondition, "pod %s is not managed by a controller and would not be recreated", pod.Name)
+	}
+
+	changes := watchInstanceChanges(ctx, s.kubeAPI, depl.Namespace)
+
+	logLine(fmt.Sprintf("Deleting pod %s in namespace %s", pod.Name, depl.Namespace))
+	err = s.kubeAPI.CoreV1().Pods(depl.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &pod.UID}})
+	if err != nil && !apierrors.IsNotFound(err) {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, "Issue with deleting pod", nil))
+		return err
+	}
+
+	err = stream.Send(prepareReadinessResponse(v1.Status_PENDING, fmt.Sprintf("Waiting for pod %s to be replaced", pod.Name), nil))
+	if err != nil {
+		return err
+	}
+	err = waitForPodReplacement(ctx, s.kubeAPI, depl.Namespace, pod.Name, pod.UID, changes)
+	if err != nil {
+		if stream.Context().Err() != nil {
+			return stream.Context().Err()
+		}
+		if ctx.Err() != nil {
+			return stream.Send(prepareReadinessResponse(v1.Status_PENDING, "Timed out waiting for instance readiness", nil))
+		}
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, "Issue with waiting for pod replacement", nil))
+		return err
+	}
+
+	return followReadiness(ctx, s.kubeAPI, depl, stream, changes)
+}
+
+func (s *readinessServiceServer) RestartInstance(req *v1.ReadinessWatchRequest, stream v1.ReadinessService_RestartInstanceServer) error {
+	logLine("> Entered RestartInstance method")
+	// check if the API version requested by client is supported by server
+	if err := checkAPI(req.Api, apiVersion); err != nil {
+		return err
+	}
+
+	depl := req.Instance
+	ctx, cancel := readinessWatchContext(stream, req.TimeoutSeconds)
+	defer cancel()
+
+	//check if given k8s namespace exists
+	_, err := s.kubeAPI.CoreV1().Namespaces().Get(ctx, depl.Namespace, metav1.GetOptions{})
+	if err != nil {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, namespaceNotFound, nil))
+		return err
+	}
+
+	workloads, err := findInstanceWorkloads(ctx, s.kubeAPI, depl.Namespace, depl.Uid)
+	if err != nil {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, "Issue with collecting workloads", nil))
+		return err
+	}
+
+	changes := watchInstanceChanges(ctx, s.kubeAPI, depl.Namespace)
+
+	now := time.Now().UTC()
+	restarted := 0
+	for _, workload := range workloads {
+		kind, name := workload.status.Kind, workload.status.Name
+		if kind != "Deployment" && kind != "StatefulSet" {
+			continue
+		}
+		logLine(fmt.Sprintf("Restarting %s %s in namespace %s", kind, name, depl.Namespace))
+		if err = restartWorkload(ctx, s.kubeAPI, depl.Namespace, kind, name, now); err != nil {
+			_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, fmt.Sprintf("Issue with restarting %s/%s", kind, name), nil))
+			return err
+		}
+		restarted++
+	}
+	if restarted == 0 {
+		_ = stream.Send(prepareReadinessResponse(v1.Status_FAILED, "No deployments or statefulsets to restart", nil))
+		return status.Errorf(codes.NotFound, "instance %s has no deployments or statefulsets", depl.Uid)
+	}
+
+	err = stream.Send(prepareReadinessResponse(v1.Status_PENDING, fmt.Sprintf("Restarted %d workloads", restarted), nil))
+	if err != nil {
+		return err
+	}
+	return followReadiness(ctx, s.kubeAPI, depl, stream, changes)
+}
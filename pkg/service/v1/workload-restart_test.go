@@ -0,0 +1,164 @@
+package v1
+
+import (
+	v1 "bitbucket.software.geant.org/projects/NMAAS/repos/nmaas-janitor/pkg/api/v1"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+//create mock deployment which is fully rolled out
+func createReadyDeployment(client *testclient.Clientset) *appsv1.Deployment {
+	depl := appsv1.Deployment{}
+	depl.Name = "test-uid"
+	depl.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test-uid"}}
+	q := int32(1)
+	depl.Spec.Replicas = &q
+	depl.Status.Replicas = q
+	depl.Status.UpdatedReplicas = q
+	depl.Status.AvailableReplicas = q
+	depl.Status.ReadyReplicas = q
+	created, _ := client.AppsV1().Deployments("test-namespace").Create(context.Background(), &depl, metav1.CreateOptions{})
+	return created
+}
+
+func TestReadinessServiceServer_RestartInstance(t *testing.T) {
+	previousInterval := readinessResyncInterval
+	t.Cleanup(func() { readinessResyncInterval = previousInterval })
+	readinessResyncInterval = 50 * time.Millisecond
+
+	client := testclient.NewSimpleClientset()
+	server := NewReadinessServiceServer(client)
+	restartReq := v1.ReadinessWatchRequest{Api: apiVersion, Instance: &inst, TimeoutSeconds: 10}
+
+	//Fail on API version check
+	err := server.RestartInstance(&v1.ReadinessWatchRequest{Api: "dummy", Instance: &inst}, newFakeServerStream[v1.ReadinessResponse]())
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	stream := newFakeServerStream[v1.ReadinessResponse]()
+	err = server.RestartInstance(&restartReq, stream)
+	if err == nil || (<-stream.responses).Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on nothing to restart
+	stream = newFakeServerStream[v1.ReadinessResponse]()
+	err = server.RestartInstance(&restartReq, stream)
+	if err == nil || (<-stream.responses).Message != "No deployments or statefulsets to restart" {
+		t.Fail()
+	}
+
+	//create mock deployment and labelled statefulset
+	createReadyDeployment(client)
+	sts := appsv1.StatefulSet{}
+	sts.Name = "test-uid-db"
+	sts.Labels = map[string]string{"app.kubernetes.io/instance": "test-uid"}
+	sts.Status.ReadyReplicas = 1
+	_, _ = client.AppsV1().StatefulSets("test-namespace").Create(context.Background(), &sts, metav1.CreateOptions{})
+
+	//Pass
+	stream = newFakeServerStream[v1.ReadinessResponse]()
+	err = server.RestartInstance(&restartReq, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := <-stream.responses
+	if res.Status != v1.Status_PENDING || res.Message != "Restarted 2 workloads" {
+		t.Fail()
+	}
+	res = <-stream.responses
+	if res.Status != v1.Status_OK || len(res.Workloads) != 2 {
+		t.Fail()
+	}
+	depl, _ := client.AppsV1().Deployments("test-namespace").Get(context.Background(), "test-uid", metav1.GetOptions{})
+	restartedAt := depl.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"]
+	if _, err = time.Parse(time.RFC3339, restartedAt); err != nil {
+		t.Fail()
+	}
+	db, _ := client.AppsV1().StatefulSets("test-namespace").Get(context.Background(), "test-uid-db", metav1.GetOptions{})
+	if db.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] != restartedAt {
+		t.Fail()
+	}
+}
+
+func TestReadinessServiceServer_RestartPod(t *testing.T) {
+	previousInterval := readinessResyncInterval
+	t.Cleanup(func() { readinessResyncInterval = previousInterval })
+	readinessResyncInterval = 50 * time.Millisecond
+
+	client := testclient.NewSimpleClientset()
+	server := NewReadinessServiceServer(client)
+	restartReq := v1.PodRestartRequest{Api: apiVersion, Instance: &inst, Pod: "test-uid-abc12", TimeoutSeconds: 10}
+
+	//Fail on API version check
+	err := server.RestartPod(&v1.PodRestartRequest{Api: "dummy", Instance: &inst}, newFakeServerStream[v1.ReadinessResponse]())
+	if err == nil {
+		t.Fail()
+	}
+
+	//Fail on namespace check
+	stream := newFakeServerStream[v1.ReadinessResponse]()
+	err = server.RestartPod(&restartReq, stream)
+	if err == nil || (<-stream.responses).Status != v1.Status_FAILED {
+		t.Fail()
+	}
+
+	//create mock namespace
+	ns := corev1.Namespace{}
+	ns.Name = "test-namespace"
+	_, _ = client.CoreV1().Namespaces().Create(context.Background(), &ns, metav1.CreateOptions{})
+
+	//Fail on pod not belonging to instance
+	stream = newFakeServerStream[v1.ReadinessResponse]()
+	err = server.RestartPod(&restartReq, stream)
+	if err == nil || (<-stream.responses).Message != "Pod not found" {
+		t.Fail()
+	}
+
+	//Fail on pod without controller
+	depl := createReadyDeployment(client)
+	pod := corev1.Pod{}
+	pod.Name = "test-uid-abc12"
+	pod.UID = "pod-uid-1"
+	pod.Labels = map[string]string{"app": "test-uid"}
+	_, _ = client.CoreV1().Pods("test-namespace").Create(context.Background(), &pod, metav1.CreateOptions{})
+	stream = newFakeServerStream[v1.ReadinessResponse]()
+	err = server.RestartPod(&restartReq, stream)
+	if err == nil || (<-stream.responses).Message != "Pod is not managed by a controller" {
+		t.Fail()
+	}
+
+	//Pass
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: depl.Name + "-5d9c", Controller: &controller}}
+	_, _ = client.CoreV1().Pods("test-namespace").Update(context.Background(), &pod, metav1.UpdateOptions{})
+	stream = newFakeServerStream[v1.ReadinessResponse]()
+	err = server.RestartPod(&restartReq, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := <-stream.responses
+	if res.Status != v1.Status_PENDING || res.Message != "Waiting for pod test-uid-abc12 to be replaced" {
+		t.Fail()
+	}
+	res = <-stream.responses
+	if res.Status != v1.Status_OK {
+		t.Fail()
+	}
+	_, err = client.CoreV1().Pods("test-namespace").Get(context.Background(), "test-uid-abc12", metav1.GetOptions{})
+	if err == nil {
+		t.Fail()
+	}
+}